@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"log"
+)
+
+// RegisterAlias declare old as deprecated name of registered option name, the
+// table of old name in config file is used as option name, with a deprecation
+// warning. Panics if option not registered, or old name already used.
+func RegisterAlias(old, name string) {
+	rec := findOption(name)
+	if rec == nil {
+		log.Panicf("[%s] register alias of unknown option '%s'", tag, name)
+	}
+	if findOption(old) != nil || findAlias(old) != nil {
+		log.Panicf("[%s] alias '%s' already registered", tag, old)
+	}
+	rec.aliases = append(rec.aliases, old)
+}
+
+// RegisterFieldAlias declare oldKey as deprecated name of key in table of
+// registered option, with a deprecation warning if oldKey used in config file.
+// Panics if option not registered, or oldKey already an alias.
+func RegisterFieldAlias(option, oldKey, key string) {
+	rec := findOption(option)
+	if rec == nil {
+		log.Panicf("[%s] register field alias of unknown option '%s'", tag, option)
+	}
+	if _, exist := rec.fieldAliases[oldKey]; exist {
+		log.Panicf("[%s] field alias '%s.%s' already registered", tag, option, oldKey)
+	}
+
+	if rec.fieldAliases == nil {
+		rec.fieldAliases = make(map[string]string)
+	}
+	rec.fieldAliases[oldKey] = key
+}
+
+func findAlias(name string) *optionRec {
+	for _, rec := range options {
+		for _, alias := range rec.aliases {
+			if alias == name {
+				return rec
+			}
+		}
+	}
+	return nil
+}
+
+func aliasConflict(old, new string) error {
+	return fmt.Errorf("[%s] both '%s' and its replacement '%s' set in config file, remove '%s'", tag, old, new, old)
+}
+
+// resolveAliases rename deprecated option tables and keys in doc to their
+// replacements.
+func resolveAliases(doc Document) error {
+	for _, rec := range options {
+		for _, alias := range rec.aliases {
+			v, err := doc.Section(alias)
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				continue
+			}
+			if sec, _ := doc.Section(rec.name); sec != nil {
+				return aliasConflict(alias, rec.name)
+			}
+
+			logWarn("deprecated option name", Field{"key", alias}, Field{"replacement", rec.name})
+			doc.deleteSection(alias)
+			doc.setSection(rec.name, v)
+		}
+
+		if len(rec.fieldAliases) == 0 {
+			continue
+		}
+		sec, err := doc.Section(rec.name)
+		if err != nil {
+			return err
+		}
+		for old, key := range rec.fieldAliases {
+			v, ok := sec[old]
+			if !ok {
+				continue
+			}
+
+			oldKey, newKey := rec.name+"."+old, rec.name+"."+key
+			if _, exist := sec[key]; exist {
+				return aliasConflict(oldKey, newKey)
+			}
+			logWarn("deprecated key", Field{"key", oldKey}, Field{"replacement", newKey})
+			delete(sec, old)
+			sec[key] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/matcher"
+)
+
+var _ = Describe("Alias", func() {
+
+	var (
+		cfg = setupTestConfig()
+		l   *recordLogger
+	)
+
+	BeforeEach(func() {
+		l = &recordLogger{}
+		SetLogger(l)
+
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+		RegisterAlias("web", "server")
+		RegisterFieldAlias("server", "Host", "Addr")
+	})
+
+	It("Old names", func() {
+		cfg.write(`[web]
+Host = ":8080"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{Addr: ":8080"}))
+		Ω(l.recs).Should(ContainElement(logRec{LevelWarn, "deprecated option name", []Field{{"key", "web"}, {"replacement", "server"}}}))
+		Ω(l.recs).Should(ContainElement(logRec{LevelWarn, "deprecated key", []Field{{"key", "server.Host"}, {"replacement", "server.Addr"}}}))
+	})
+
+	It("New names", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{Addr: ":8080"}))
+		for _, rec := range l.recs {
+			Ω(rec.level).ShouldNot(Equal(LevelWarn))
+		}
+	})
+
+	It("Option conflict", func() {
+		cfg.write(`[web]
+[server]
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] both 'web' and its replacement 'server' set in config file, remove 'web'"))
+	})
+
+	It("Field conflict", func() {
+		cfg.write(`[server]
+Host = ":8080"
+Addr = ":8081"
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] both 'server.Host' and its replacement 'server.Addr' set in config file, remove 'server.Host'"))
+	})
+
+	It("Alias name used", func() {
+		Ω(func() {
+			Register("web", newSimpleOption("bar"))
+		}).Should(matcher.Panics("[config] option 'web' already registered as alias"))
+		Ω(func() {
+			RegisterAlias("server", "server")
+		}).Should(matcher.Panics("[config] alias 'server' already registered"))
+	})
+
+})
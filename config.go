@@ -1,16 +1,16 @@
 package config
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
+	"sync"
 
 	"github.com/redforks/life"
 
 	"github.com/redforks/testing/reset"
 
-	"github.com/BurntSushi/toml"
 	"github.com/urfave/cli"
 )
 
@@ -22,6 +22,24 @@ type optionRec struct {
 	name    string
 	op      Option
 	creator OptionCreator
+
+	// option instance passed to Init(), values of restart-required fields
+	// stay in effect until restart.
+	initOp Option
+
+	version Version
+
+	migrations []Migration
+
+	// deprecated option names, and deprecated key names to their replacements
+	aliases      []string
+	fieldAliases map[string]string
+
+	// instance creator and callbacks of OptionMap, nil for other options
+	item *mapRec
+
+	// provenance of fields of op
+	sources *optionSources
 }
 
 // The OptionCreator is a factory function to create option interface
@@ -33,10 +51,22 @@ var (
 	// monitor signal routine, which also has one gorutine.
 	options []*optionRec
 
+	// optionsLock guards options and records changed after load, for readers
+	// in other goroutines such as FieldProvenance(). Changes after
+	// life.Initing phase also hold reloadLock, code holding reloadLock reads
+	// without optionsLock.
+	optionsLock sync.RWMutex
+
 	// configuration file
 	filename string
 	loaded   bool
 
+	// options inited, by Load() or by life.Start() in test mode
+	inited bool
+
+	// config file of applied options, nil if not loaded from file
+	appliedFile *fileSource
+
 	// Flag is config file command line flag, add Flag to your cli.App.Flags.
 	Flag cli.Flag = &cli.StringFlag{
 		Name:        "config, c",
@@ -52,11 +82,24 @@ var (
 		Usage:       "Dump default options to stdout, save it as config file",
 		Destination: &dumpDefaultOptions,
 	}
+
+	checkConfig bool
+
+	// FlagCheckConfig validate config file and exit if enabled, exit status is
+	// non-zero if config file invalid. Add to your cli.App.Flags.
+	FlagCheckConfig cli.Flag = &cli.BoolFlag{
+		Name:        "checkConfig",
+		Usage:       "Check config file and exit, exit status is non-zero if config file invalid",
+		Destination: &checkConfig,
+	}
 )
 
 // ResetInternal reset config package internal state, all registered options lost.
 // It is mainly used in unit tests of config package itself.
 func ResetInternal() {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+
 	options = nil
 	filename = ""
 }
@@ -74,11 +117,34 @@ type Option interface {
 }
 
 // Register an option object. Panic on name conflict.
+// name can be hierarchical such as "db.primary", maps to nested table
+// [db.primary] in config file, a name can not be parent of other names, such
+// as "db" and "db.primary".
+// Top level table [profile] holds profiles, name "profile" and names under it
+// are reserved. This breaks existing option named "profile", rename the option
+// and its table in config files.
 // fnCreateDefault is a func create default value, must not return nil.
 // fnCreateDefault may called multiple times, each time should create a new
 // instance.
 // Registered options not reset by spork/testing/reset package.
+//
+// Use RegisterLate() to register after life.Initing phase.
 func Register(name string, fnCreateDefault OptionCreator) {
+	checkOptionName(name)
+
+	life.EnsureStatef(life.Initing, "[%s] must register '%s' Option at life Initing phase", tag, name)
+
+	checkRegister(name, fnCreateDefault)
+	addOption(&optionRec{name: name, creator: fnCreateDefault})
+}
+
+func addOption(rec *optionRec) {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+	options = append(options, rec)
+}
+
+func checkOptionName(name string) {
 	// https://github.com/toml-lang/toml#user-content-spec:
 	//
 	// Keys may be either bare or quoted. Bare keys may only contain letters,
@@ -86,13 +152,16 @@ func Register(name string, fnCreateDefault OptionCreator) {
 	// allowed to be composed of only digits, e.g. 1234.
 	//
 	// Although special character such as '.', '/' can be used by quote, but it
-	// is complex and confusing, totally unnecessary.
-	if ok, _ := regexp.Match("^[a-zA-Z0-9_-]+$", []byte(name)); !ok {
+	// is complex and confusing, totally unnecessary. Dotted name such as
+	// "db.primary" is hierarchical, maps to nested table [db.primary].
+	if ok, _ := regexp.Match(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`, []byte(name)); !ok {
 		log.Panicf("[%s] bad option name '%s'", tag, name)
 	}
 
-	life.EnsureStatef(life.Initing, "[%s] must register '%s' Option at life Initing phase", tag, name)
+	checkReservedName(name)
+}
 
+func checkRegister(name string, fnCreateDefault OptionCreator) {
 	if fnCreateDefault == nil {
 		log.Panicf("[%s] can not register nil Option '%s'", tag, name)
 	}
@@ -101,36 +170,60 @@ func Register(name string, fnCreateDefault OptionCreator) {
 		if rec.name == name {
 			log.Panicf("[%s] option '%s' already registered", tag, name)
 		}
+		if strings.HasPrefix(rec.name, name+".") || strings.HasPrefix(name, rec.name+".") {
+			log.Panicf("[%s] option '%s' conflicts with '%s', a table can not be both option and parent of options", tag, name, rec.name)
+		}
+	}
+	if findAlias(name) != nil {
+		log.Panicf("[%s] option '%s' already registered as alias", tag, name)
 	}
-	options = append(options, &optionRec{name, nil, fnCreateDefault})
 }
 
-func optionChanged(op1, op2 Option) bool {
-	buf1, buf2 := &bytes.Buffer{}, &bytes.Buffer{}
-	if err := toml.NewEncoder(buf1).Encode(op1); err != nil {
-		log.Panicf(err.Error())
+// getDefaultOptions returns default options and provenance of their fields,
+// in options order.
+func getDefaultOptions() ([]Option, []*optionSources, error) {
+	opts := make([]Option, len(options))
+	srcs := make([]*optionSources, len(options))
+	for i, rec := range options {
+		var err error
+		if opts[i], srcs[i], err = defaultOption(rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if name, exist := getAnyKey(overrideDefOptions); exist {
+		return nil, nil, fmt.Errorf("[%s] overridden option \"%s\" not used, wrong option name?", tag, name)
 	}
-	if err := toml.NewEncoder(buf2).Encode(op2); err != nil {
-		log.Panicf(err.Error())
+	return opts, srcs, nil
+}
+
+// defaultOption returns default option of rec, overridden for tests if set,
+// and provenance of its fields.
+func defaultOption(rec *optionRec) (Option, *optionSources, error) {
+	var op Option
+	src := newOptionSources(SourceOverride)
+	if o := scopedOption(rec.name); o != nil {
+		op = o
+	} else if o := overrideDefOptions[rec.name]; o != nil {
+		op = o
+		delete(overrideDefOptions, rec.name)
+	} else {
+		op = rec.creator()
+		src.base.Kind = SourceDefault
 	}
 
-	return buf1.String() != buf2.String()
+	if err := applyScopedFields(rec.name, op, src); err != nil {
+		return nil, nil, err
+	}
+	return op, src, nil
 }
 
-func getDefaultOptions() ([]Option, error) {
+// newOptions creates default options by their creators, in options order.
+func newOptions() []Option {
 	opts := make([]Option, len(options))
 	for i, rec := range options {
-		if o := overrideDefOptions[rec.name]; o != nil {
-			opts[i] = o
-			delete(overrideDefOptions, rec.name)
-		} else {
-			opts[i] = rec.creator()
-		}
+		opts[i] = rec.creator()
 	}
-	if name, exist := getAnyKey(overrideDefOptions); exist {
-		return nil, fmt.Errorf("[%s] overridden option \"%s\" not used, wrong option name?", tag, name)
-	}
-	return opts, nil
+	return opts
 }
 
 func getAnyKey(v map[string]Option) (string, bool) {
@@ -140,34 +233,45 @@ func getAnyKey(v map[string]Option) (string, bool) {
 	return "", false
 }
 
-func initAllOptions(opts []Option) error {
+func initAllOptions(opts []Option, srcs []*optionSources) error {
 	var err error
 	if opts == nil {
-		if opts, err = getDefaultOptions(); err != nil {
+		if opts, srcs, err = getDefaultOptions(); err != nil {
 			return err
 		}
 	}
 
 	for i, rec := range options {
-		log.Printf("[%s] initing option '%s'", tag, rec.name)
+		logDebug("initing option", Field{"option", rec.name})
 		if err := opts[i].Init(); err != nil {
 			return err
 		}
 	}
-	log.Printf("[%s] inited all options", tag)
+	logInfo("inited all options")
 
-	storeOptions(opts)
+	storeOptions(opts, srcs)
+	optionsLock.Lock()
+	for _, rec := range options {
+		rec.initOp = rec.op
+	}
+	inited = true
+	optionsLock.Unlock()
 	return nil
 }
 
-func storeOptions(opts []Option) {
+func storeOptions(opts []Option, srcs []*optionSources) {
+	optionsLock.Lock()
 	for i, rec := range options {
-		rec.op = opts[i]
+		rec.op, rec.sources = opts[i], srcs[i]
 	}
+	optionsLock.Unlock()
+	updateVersions()
 }
 
 func init() {
 	reset.Register(func() {
 		loaded = false
+		inited = false
+		appliedFile = nil
 	}, nil)
 }
@@ -1,8 +1,16 @@
 package config_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/redforks/config"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/iotest"
+	"github.com/redforks/testing/reset"
 
 	"testing"
 )
@@ -11,3 +19,47 @@ func TestConfig(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Config Suite")
 }
+
+// testConfig is fixture shared by specs loading config files, file is
+// app.conf in a new temp directory of each spec.
+type testConfig struct {
+	dir  iotest.TempTestDir
+	file string
+}
+
+// setupTestConfig adds BeforeEach and AfterEach to current container. Before
+// each spec: enable reset, create temp directory and clear records of fake
+// options; after each spec: reset config package. Call it before other
+// BeforeEach of the container, so they run after the fixture ready.
+func setupTestConfig() *testConfig {
+	c := &testConfig{}
+
+	BeforeEach(func() {
+		reset.Enable()
+		c.dir = iotest.NewTempTestDir()
+		c.file = c.path("app.conf")
+		ops, initHits, applyHits, initErrors = nil, nil, nil, nil
+		addrOp = nil
+	})
+
+	AfterEach(func() {
+		ResetInternal()
+		reset.Disable()
+	})
+
+	return c
+}
+
+// path returns path of name in temp directory.
+func (c *testConfig) path(name string) string {
+	return filepath.Join(c.dir.Dir(), name)
+}
+
+// write content to config file.
+func (c *testConfig) write(content string) {
+	writeConfigFile(c.file, content)
+}
+
+func writeConfigFile(path, content string) {
+	Ω(ioutil.WriteFile(path, []byte(content), os.ModePerm)).Should(Succeed())
+}
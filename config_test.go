@@ -2,17 +2,13 @@ package config_test
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 
 	. "github.com/redforks/config"
 
 	"github.com/redforks/life"
 
-	"github.com/redforks/testing/iotest"
 	"github.com/redforks/testing/matcher"
-	"github.com/redforks/testing/reset"
 
 	"context"
 
@@ -25,47 +21,25 @@ import (
 var _ = Describe("config", func() {
 
 	var (
-		testDir  iotest.TempTestDir
-		filename string
-	)
-
-	var (
-		writeConfigFile = func(content string) {
-			Ω(ioutil.WriteFile(filename, []byte(content), os.ModePerm)).Should(Succeed())
-		}
+		cfg = setupTestConfig()
 
 		loadNoError = func() {
-			Ω(Load(filename)).Should(Succeed())
+			Ω(Load(cfg.file)).Should(Succeed())
 		}
 	)
 
-	BeforeEach(func() {
-		reset.Enable()
-		testDir = iotest.NewTempTestDir()
-		filename = filepath.Join(testDir.Dir(), "app.conf")
-		ops = nil
-		initHits = nil
-		applyHits = nil
-		initErrors = nil
-	})
-
-	AfterEach(func() {
-		ResetInternal()
-		reset.Disable()
-	})
-
 	It("Empty - config file not exist", func() {
 		loadNoError()
 	})
 
 	It("Empty - config file exist", func() {
-		writeConfigFile("")
+		cfg.write("")
 		loadNoError()
 	})
 
 	It("Open config file failed", func() {
-		writeConfigFile("[ab")
-		Ω(Load(filename)).Should(HaveOccurred())
+		cfg.write("[ab")
+		Ω(Load(cfg.file)).Should(HaveOccurred())
 	})
 
 	It("Dup name", func() {
@@ -83,7 +57,9 @@ var _ = Describe("config", func() {
 		}
 		badName("")
 		badName("a/b")
-		badName("a.b")
+		badName("a..b")
+		badName(".a")
+		badName("a.")
 	})
 
 	It("option can not be nil", func() {
@@ -106,14 +82,14 @@ var _ = Describe("config", func() {
 		})
 
 		It("Empty config file", func() {
-			writeConfigFile("")
+			cfg.write("")
 			loadNoError()
 			Ω(ops[0].Name).Should(Equal("bar"))
 			Ω(ops[0].Count).Should(Equal(3))
 		})
 
 		It("Config file with partial value", func() {
-			writeConfigFile(`[foo]
+			cfg.write(`[foo]
 Name = "foobar"
 			`)
 			loadNoError()
@@ -125,22 +101,22 @@ Name = "foobar"
 		})
 
 		It("Abort if Init() return error", func() {
-			writeConfigFile(`[foo]
+			cfg.write(`[foo]
 Name = "foobar"
 			`)
 			initErrors = []error{errors.New("error")}
-			Ω(Load(filename)).Should(Equal(initErrors[0]))
+			Ω(Load(cfg.file)).Should(Equal(initErrors[0]))
 		})
 
 		It("Init() called even not mentioned in config file", func() {
-			writeConfigFile("")
+			cfg.write("")
 			initErrors = []error{errors.New("error")}
-			Ω(Load(filename)).Should(Equal(initErrors[0]))
+			Ω(Load(cfg.file)).Should(Equal(initErrors[0]))
 		})
 
 		It("Init() called when no config file", func() {
 			initErrors = []error{errors.New("error")}
-			Ω(Load(filename)).Should(Equal(initErrors[0]))
+			Ω(Load(cfg.file)).Should(Equal(initErrors[0]))
 		})
 
 		It("Reload on SIGUSR1", func() {
@@ -148,7 +124,7 @@ Name = "foobar"
 			Ω(initHits[0]).Should(Equal(1))
 			Ω(applyHits[0]).Should(Equal(0))
 
-			writeConfigFile(`[foo]
+			cfg.write(`[foo]
 Name = "bar1"
 			`)
 			Reload()
@@ -191,7 +167,7 @@ Name = "bar1"
 				})
 
 				loadNoError()
-				writeConfigFile(`[foo]
+				cfg.write(`[foo]
 Panic = true
 			`)
 			})
@@ -221,7 +197,7 @@ Panic = true
 			Register("op1", newFakeOption(0))
 			Register("op2", newFakeOption(1))
 			Register("op3", newFakeOption(2))
-			Ω(Load(filename)).Should(Equal(initErrors[1]))
+			Ω(Load(cfg.file)).Should(Equal(initErrors[1]))
 			Ω(initHits[0]).Should(Equal(1))
 			Ω(initHits[1]).Should(Equal(1))
 			Ω(ops).Should(HaveLen(2))
@@ -232,7 +208,7 @@ Panic = true
 			Register("op2", newFakeOption(1))
 			Register("op3", newFakeOption(2))
 			Register("op4", newFakeOption(3))
-			writeConfigFile(`[op1]
+			cfg.write(`[op1]
 Name = "foo"
 
 [op2]
@@ -243,7 +219,7 @@ Name = "op4"
 `)
 			loadNoError()
 
-			writeConfigFile(`[op1]
+			cfg.write(`[op1]
 Name = "foo"
 
 [op2]
@@ -268,7 +244,7 @@ Name = "wow"
 			Ω(ops[2].Name).Should(Equal("wow"))
 			Ω(ops[3].Name).Should(Equal("bar"))
 
-			writeConfigFile(`[op1]
+			cfg.write(`[op1]
 Name = "foo"
 
 [op2]
@@ -292,7 +268,7 @@ Name = "foobar1"
 
 		It("Load twice", func() {
 			loadNoError()
-			Ω(Load(filename)).Should(MatchError("[config] can not call Load() twice"))
+			Ω(Load(cfg.file)).Should(MatchError("[config] can not call Load() twice"))
 		})
 
 	})
@@ -0,0 +1,222 @@
+// Package configtest helps testing code driven by config package.
+//
+// An Env loads options from inline TOML, changes individual fields, and
+// simulates reloads recording which options got Apply() calls. Env manages
+// testing/reset state, do not call reset.Enable()/reset.Disable() with it.
+//
+// With plain testing package:
+//
+//	func TestFoo(t *testing.T) {
+//		env := configtest.Setup(t)
+//		config.Register("foo", newFooOption)
+//		if err := env.Load(`[foo]
+//	Name = "bar"`); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// With Ginkgo, create Env by New() in BeforeEach, and Close() in AfterEach.
+package configtest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/redforks/config"
+	"github.com/redforks/testing/reset"
+)
+
+const tag = "configtest"
+
+// Env is a config test environment, with a temp config file.
+type Env struct {
+	dir  string
+	file string
+
+	doc     config.Document
+	loaded  bool
+	applied []string
+}
+
+// New creates Env and enables testing/reset, call Close() after test.
+func New() (*Env, error) {
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		return nil, err
+	}
+
+	reset.Enable()
+	env := &Env{
+		dir:  dir,
+		file: filepath.Join(dir, "test.conf"),
+		doc:  config.Document{},
+	}
+	config.AddApplyHook(func(ev config.ApplyEvent) {
+		env.applied = append(env.applied, ev.Option)
+	})
+	return env, nil
+}
+
+// Setup creates Env for t, closed automatically when t and its subtests
+// finished.
+func Setup(t *testing.T) *Env {
+	t.Helper()
+	env, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(env.Close)
+	return env
+}
+
+// Override opens a config.OverrideScope for t, closed automatically when t
+// finished, unused overrides fail t.
+func Override(t *testing.T) *config.OverrideScope {
+	t.Helper()
+	s := config.NewOverrideScope()
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+// Close resets config package, all registered options lost, and removes the
+// temp config file.
+func (e *Env) Close() {
+	config.ResetInternal()
+	reset.Disable()
+	_ = os.RemoveAll(e.dir)
+}
+
+// File returns path of the temp config file.
+func (e *Env) File() string {
+	return e.file
+}
+
+// Load options from content in TOML format, merged with fields set by Set()
+// before Load(). All registered options are inited.
+func (e *Env) Load(content string) error {
+	doc, err := parse(content)
+	if err != nil {
+		return err
+	}
+	for k, v := range e.doc {
+		if err = merge(doc, k, v); err != nil {
+			return err
+		}
+	}
+
+	e.doc = doc
+	if err = e.write(); err != nil {
+		return err
+	}
+	if err = config.Load(e.file); err != nil {
+		return err
+	}
+	e.loaded = true
+	return nil
+}
+
+// Set field key of option to value, key can be dotted for nested tables such
+// as "DB.Host", option can be hierarchical name such as "db.primary". Before
+// Load(), value is used by Load(); after Load(), config reloaded, use
+// Applied() to get applied options.
+func (e *Env) Set(option, key string, value interface{}) error {
+	path := append(strings.Split(option, "."), strings.Split(key, ".")...)
+	if err := setPath(e.doc, path, value); err != nil {
+		return err
+	}
+
+	if !e.loaded {
+		return nil
+	}
+	return e.reload()
+}
+
+// Reload replace config file with content in TOML format, and reload it.
+// Returns names of options got Apply() calls.
+func (e *Env) Reload(content string) ([]string, error) {
+	doc, err := parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	e.doc = doc
+	if err = e.reload(); err != nil {
+		return nil, err
+	}
+	return e.Applied(), nil
+}
+
+// Applied returns names of options got Apply() calls by last reload, by
+// Reload() or Set() after Load().
+func (e *Env) Applied() []string {
+	return append([]string(nil), e.applied...)
+}
+
+func (e *Env) reload() error {
+	if err := e.write(); err != nil {
+		return err
+	}
+
+	e.applied = nil
+	return config.SwitchFile(e.file)
+}
+
+func (e *Env) write() error {
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(e.doc); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(e.file, buf.Bytes(), 0600)
+}
+
+func parse(content string) (config.Document, error) {
+	doc := config.Document{}
+	if _, err := toml.Decode(content, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// merge set value of key to doc, tables merged recursively.
+func merge(doc map[string]interface{}, key string, v interface{}) error {
+	table, ok := v.(map[string]interface{})
+	if !ok {
+		doc[key] = v
+		return nil
+	}
+
+	for k, item := range table {
+		if err := setPath(doc, []string{key, k}, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setPath(doc map[string]interface{}, path []string, v interface{}) error {
+	for _, p := range path[:len(path)-1] {
+		sub, exist := doc[p]
+		if !exist {
+			sub = make(map[string]interface{})
+			doc[p] = sub
+		}
+
+		table, ok := sub.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("[%s] can not set '%s', '%s' is not a table", tag, strings.Join(path, "."), p)
+		}
+		doc = table
+	}
+
+	return merge(doc, path[len(path)-1], v)
+}
@@ -0,0 +1,13 @@
+package configtest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestConfigtest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Configtest Suite")
+}
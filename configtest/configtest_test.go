@@ -0,0 +1,215 @@
+package configtest_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/redforks/config"
+	. "github.com/redforks/config/configtest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fooOption struct {
+	Name  string
+	Count int
+	DB    struct {
+		Host string
+	}
+}
+
+var foo *fooOption
+
+func (o *fooOption) Init() error {
+	foo = o
+	return nil
+}
+
+func (o *fooOption) Apply() {
+	foo = o
+}
+
+func newFooOption() config.Option {
+	return &fooOption{Name: "bar", Count: 3}
+}
+
+// level is a custom type, its hook registered in init() as consumer packages
+// do.
+type level int
+
+type levelOption struct {
+	Level level
+}
+
+var lvl *levelOption
+
+func (o *levelOption) Init() error {
+	lvl = o
+	return nil
+}
+
+func (o *levelOption) Apply() {
+	lvl = o
+}
+
+func init() {
+	config.RegisterTypeHook(reflect.TypeOf(level(0)), config.TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			if v == "high" {
+				return level(1), nil
+			}
+			return level(0), nil
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return [...]string{"low", "high"}[v.(level)], nil
+		},
+	})
+}
+
+type barOption struct {
+	Count int
+}
+
+func (o *barOption) Init() error {
+	return nil
+}
+
+func (o *barOption) Apply() {
+}
+
+func newBarOption() config.Option {
+	return &barOption{}
+}
+
+var _ = Describe("Env", func() {
+
+	var env *Env
+
+	BeforeEach(func() {
+		var err error
+		env, err = New()
+		Ω(err).Should(Succeed())
+		foo = nil
+		config.Register("foo", newFooOption)
+		config.Register("bar", newBarOption)
+	})
+
+	AfterEach(func() {
+		env.Close()
+	})
+
+	It("Load", func() {
+		Ω(env.Load(`[foo]
+Name = "foobar"
+`)).Should(Succeed())
+		Ω(foo.Name).Should(Equal("foobar"))
+		Ω(foo.Count).Should(Equal(3))
+	})
+
+	It("Set before load", func() {
+		Ω(env.Set("foo", "Count", 5)).Should(Succeed())
+		Ω(env.Set("foo", "DB.Host", "localhost")).Should(Succeed())
+		Ω(env.Load(`[foo]
+Name = "foobar"
+Count = 4
+`)).Should(Succeed())
+		Ω(foo.Name).Should(Equal("foobar"))
+		Ω(foo.Count).Should(Equal(5))
+		Ω(foo.DB.Host).Should(Equal("localhost"))
+	})
+
+	It("Set after load", func() {
+		Ω(env.Load("")).Should(Succeed())
+		Ω(env.Set("foo", "Count", 5)).Should(Succeed())
+		Ω(foo.Count).Should(Equal(5))
+		Ω(env.Applied()).Should(Equal([]string{"foo"}))
+	})
+
+	It("Reload", func() {
+		Ω(env.Load("")).Should(Succeed())
+		Ω(env.Reload(`[bar]
+Count = 1
+`)).Should(Equal([]string{"bar"}))
+		Ω(env.Reload(`[bar]
+Count = 1
+`)).Should(BeEmpty())
+	})
+
+	It("Reload error", func() {
+		Ω(env.Load("")).Should(Succeed())
+		_, err := env.Reload(`[bar]
+Count = "a"
+`)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("Set non table", func() {
+		Ω(env.Set("foo", "Name", "a")).Should(Succeed())
+		Ω(env.Set("foo", "Name.Sub", "a")).Should(MatchError("[configtest] can not set 'foo.Name.Sub', 'Name' is not a table"))
+	})
+
+})
+
+func TestSetup(t *testing.T) {
+	t.Run("load", func(t *testing.T) {
+		env := Setup(t)
+		config.Register("foo", newFooOption)
+		if err := env.Load(`[foo]
+Name = "foobar"
+`); err != nil {
+			t.Fatal(err)
+		}
+		if foo.Name != "foobar" {
+			t.Errorf("expected foobar, got %s", foo.Name)
+		}
+	})
+
+	// options registered by previous subtest are reset
+	t.Run("reset", func(t *testing.T) {
+		env := Setup(t)
+		config.Register("foo", newFooOption)
+		if err := env.Load(""); err != nil {
+			t.Fatal(err)
+		}
+		if foo.Name != "bar" {
+			t.Errorf("expected bar, got %s", foo.Name)
+		}
+	})
+}
+
+func TestOverride(t *testing.T) {
+	for _, count := range []int{1, 2} {
+		t.Run("", func(t *testing.T) {
+			env := Setup(t)
+			config.Register("foo", newFooOption)
+			Override(t).Field("foo", "Count", count)
+			if err := env.Load(""); err != nil {
+				t.Fatal(err)
+			}
+			if foo.Count != count {
+				t.Errorf("expected %d, got %d", count, foo.Count)
+			}
+		})
+	}
+}
+
+// type hooks registered in init() survive Env.Close()
+func TestTypeHookKept(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		t.Run("", func(t *testing.T) {
+			env := Setup(t)
+			config.Register("lvl", func() config.Option {
+				return &levelOption{}
+			})
+			if err := env.Load(`[lvl]
+Level = "high"
+`); err != nil {
+				t.Fatal(err)
+			}
+			if lvl.Level != 1 {
+				t.Errorf("expected 1, got %d", lvl.Level)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/redforks/hal"
+	"github.com/redforks/testing/reset"
+)
+
+// encryptedPrefix marks an encrypted string value in config file, followed by
+// base64 of nonce and AES-256-GCM sealed value.
+const encryptedPrefix = "enc:v1:"
+
+// KeySize is the size of key returned by KeyProvider, for AES-256.
+const KeySize = 32
+
+// KeyProvider provides the key to decrypt encrypted values in config file.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// KeyProviderFunc adapts a function to KeyProvider.
+type KeyProviderFunc func() ([]byte, error)
+
+// Key calls f().
+func (f KeyProviderFunc) Key() ([]byte, error) {
+	return f()
+}
+
+type keyFile string
+
+// KeyFile returns a KeyProvider reads base64 encoded key from file at path,
+// the file is checked by PermPolicy.
+func KeyFile(path string) KeyProvider {
+	return keyFile(path)
+}
+
+func (f keyFile) Key() ([]byte, error) {
+	if err := checkFilePerm(string(f)); err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+	return decodeKey(string(buf), string(f))
+}
+
+// KeyEnv returns a KeyProvider reads base64 encoded key from environment
+// variable name.
+func KeyEnv(name string) KeyProvider {
+	return KeyProviderFunc(func() ([]byte, error) {
+		v := hal.Getenv(name)
+		if v == "" {
+			return nil, fmt.Errorf("environment variable '%s' not set", name)
+		}
+		return decodeKey(v, name)
+	})
+}
+
+func decodeKey(s, from string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("bad key in '%s': %s", from, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("bad key in '%s': should be %d bytes", from, KeySize)
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random key in base64, save it to key file or
+// environment variable.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+var keyProvider KeyProvider
+
+// SetKeyProvider set KeyProvider used to decrypt values in config file, and
+// encrypt by EncryptValue().
+func SetKeyProvider(p KeyProvider) {
+	keyProvider = p
+}
+
+func newAEAD() (cipher.AEAD, error) {
+	if keyProvider == nil {
+		return nil, errors.New("no KeyProvider set")
+	}
+
+	key, err := keyProvider.Key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptValue encrypt plain text by key of current KeyProvider, the returned
+// value can be used as string value in config file, such as:
+//
+//	Password = "enc:v1:..."
+func EncryptValue(plain string) (string, error) {
+	aead, err := newAEAD()
+	if err != nil {
+		return "", fmt.Errorf("[%s] encrypt: %s", tag, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptValue(aead cipher.AEAD, v string) (string, error) {
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(buf) < aead.NonceSize() {
+		return "", errors.New("too short")
+	}
+
+	plain, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// decryptDocument decrypt encrypted values in all option tables of doc.
+func decryptDocument(doc Document) error {
+	var aead cipher.AEAD
+	return walkSections(doc, func(path, s string) (string, error) {
+		if !strings.HasPrefix(s, encryptedPrefix) {
+			return s, nil
+		}
+
+		if aead == nil {
+			var err error
+			if aead, err = newAEAD(); err != nil {
+				return "", fmt.Errorf("[%s] decrypt '%s': %s", tag, path, err)
+			}
+		}
+
+		plain, err := decryptValue(aead, s)
+		if err != nil {
+			return "", fmt.Errorf("[%s] decrypt '%s': %s", tag, path, err)
+		}
+		return plain, nil
+	})
+}
+
+func init() {
+	reset.Register(func() {
+		keyProvider = nil
+	}, nil)
+}
@@ -0,0 +1,89 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/hal"
+)
+
+var _ = Describe("Encrypted values", func() {
+
+	var (
+		cfg = setupTestConfig()
+		key string
+	)
+
+	BeforeEach(func() {
+		var err error
+		key, err = GenerateKey()
+		Ω(err).Should(Succeed())
+
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+	})
+
+	loadEncrypted := func() error {
+		v, err := EncryptValue("secret")
+		Ω(err).Should(Succeed())
+		Ω(v).Should(HavePrefix("enc:v1:"))
+		cfg.write(`[server]
+Addr = "` + v + `"
+`)
+		return Load(cfg.file)
+	}
+
+	It("Key file", func() {
+		keyFile := cfg.path("key")
+		writeConfigFile(keyFile, key+"\n")
+		SetKeyProvider(KeyFile(keyFile))
+		Ω(loadEncrypted()).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal("secret"))
+	})
+
+	It("Key environment variable", func() {
+		hal.Getenv = func(name string) string {
+			if name == "APP_KEY" {
+				return key
+			}
+			return ""
+		}
+		SetKeyProvider(KeyEnv("APP_KEY"))
+		Ω(loadEncrypted()).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal("secret"))
+	})
+
+	It("Wrong key", func() {
+		SetKeyProvider(KeyProviderFunc(func() ([]byte, error) {
+			return make([]byte, KeySize), nil
+		}))
+		v, err := EncryptValue("secret")
+		Ω(err).Should(Succeed())
+
+		keyFile := cfg.path("key")
+		writeConfigFile(keyFile, key)
+		SetKeyProvider(KeyFile(keyFile))
+		cfg.write(`[server]
+Addr = "` + v + `"
+`)
+		Ω(Load(cfg.file)).Should(MatchError(HavePrefix("[config] decrypt 'server.Addr': ")))
+	})
+
+	It("No key provider", func() {
+		cfg.write(`[server]
+Addr = "enc:v1:AAAA"
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] decrypt 'server.Addr': no KeyProvider set"))
+	})
+
+	It("Bad key", func() {
+		keyFile := cfg.path("key")
+		writeConfigFile(keyFile, "AAAA")
+		SetKeyProvider(KeyFile(keyFile))
+		_, err := EncryptValue("secret")
+		Ω(err).Should(MatchError("[config] encrypt: bad key in '" + keyFile + "': should be 32 bytes"))
+	})
+
+})
@@ -1,8 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"log"
+	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/BurntSushi/toml"
+	"github.com/redforks/errors"
 	"github.com/redforks/testing/reset"
 )
 
@@ -29,5 +36,210 @@ func SetDefaultOptionForTest(pkg string, op Option) {
 func init() {
 	reset.Register(func() {
 		overrideDefOptions = make(map[string]Option)
+		overrideScope = nil
 	}, nil)
 }
+
+// OverrideScope overrides default options for a test, scopes can be nested,
+// inner scope wins. Unlike SetDefaultOptionForTest(), overrides can be set
+// more than once, and unused overrides are reported by Close() at test end.
+type OverrideScope struct {
+	parent *OverrideScope
+
+	options map[string]Option
+
+	// option name -> field key path -> value
+	fields map[string]map[string]interface{}
+
+	// used options, and used fields in "option.Key" form
+	used map[string]bool
+}
+
+var overrideScope *OverrideScope
+
+// NewOverrideScope opens a scope nested in current scope, must Close() it
+// after test. Panics if not in unit tests.
+func NewOverrideScope() *OverrideScope {
+	if !reset.TestMode() {
+		panic("NewOverrideScope can only be used in unit tests")
+	}
+
+	overrideScope = &OverrideScope{
+		parent:  overrideScope,
+		options: make(map[string]Option),
+		fields:  make(map[string]map[string]interface{}),
+		used:    make(map[string]bool),
+	}
+	return overrideScope
+}
+
+// Option replaces default option name by op, each load uses a deep copy of
+// op, op itself never decoded into or inited.
+func (s *OverrideScope) Option(name string, op Option) *OverrideScope {
+	s.options[name] = op
+	return s
+}
+
+// Field overrides field key of default option name, key can be dotted for
+// nested struct, such as "DB.Host". value should be encodable to toml, it is
+// decoded the same as in config file, such as "5s" for time.Duration.
+func (s *OverrideScope) Field(name, key string, value interface{}) *OverrideScope {
+	if s.fields[name] == nil {
+		s.fields[name] = make(map[string]interface{})
+	}
+	s.fields[name][key] = value
+	return s
+}
+
+// Close the scope, restores overrides of outer scope. Returns error lists
+// overrides not used by any load, possibly wrong option name or field key.
+func (s *OverrideScope) Close() error {
+	if overrideScope != s {
+		return errors.Bugf("[%s] close OverrideScope not innermost", tag)
+	}
+	overrideScope = s.parent
+
+	var unused []string
+	for name := range s.options {
+		if !s.used[name] {
+			unused = append(unused, name)
+		}
+	}
+	for name, fields := range s.fields {
+		for key := range fields {
+			if !s.used[name+"."+key] {
+				unused = append(unused, name+"."+key)
+			}
+		}
+	}
+	if len(unused) != 0 {
+		sort.Strings(unused)
+		return fmt.Errorf("[%s] overrides not used, wrong option name or field? %s", tag, strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// scopedOption returns copy of overridden option in innermost scope, each
+// load decodes config file into a new instance, the same as options created
+// by their creators.
+func scopedOption(name string) Option {
+	for s := overrideScope; s != nil; s = s.parent {
+		if op, ok := s.options[name]; ok {
+			s.used[name] = true
+			return copyOption(op)
+		}
+	}
+	return nil
+}
+
+// copyOption returns deep copy of op. Only exported fields copied deeply,
+// unexported fields are shallow copied, such as pointer to counters of tests.
+func copyOption(op Option) Option {
+	return deepCopy(reflect.ValueOf(op), make(map[uintptr]reflect.Value)).Interface().(Option)
+}
+
+// deepCopy returns deep copy of v, copied keyed by address of copied pointers
+// to keep reference cycles and shared pointers.
+func deepCopy(v reflect.Value, copied map[uintptr]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if r, ok := copied[v.Pointer()]; ok && r.Type() == v.Type() {
+			return r
+		}
+		r := reflect.New(v.Type().Elem())
+		copied[v.Pointer()] = r
+		r.Elem().Set(deepCopy(v.Elem(), copied))
+		return r
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		r := reflect.New(v.Type()).Elem()
+		r.Set(deepCopy(v.Elem(), copied))
+		return r
+	case reflect.Struct:
+		r := reflect.New(v.Type()).Elem()
+		r.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				r.Field(i).Set(deepCopy(v.Field(i), copied))
+			}
+		}
+		return r
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		r := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			r.SetMapIndex(iter.Key(), deepCopy(iter.Value(), copied))
+		}
+		return r
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		r := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			r.Index(i).Set(deepCopy(v.Index(i), copied))
+		}
+		return r
+	case reflect.Array:
+		r := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			r.Index(i).Set(deepCopy(v.Index(i), copied))
+		}
+		return r
+	}
+	return v
+}
+
+// applyScopedFields set overridden fields of all scopes to op, inner scope
+// wins. Provenance of overridden fields recorded to src.
+func applyScopedFields(name string, op Option, src *optionSources) error {
+	var scopes []*OverrideScope
+	for s := overrideScope; s != nil; s = s.parent {
+		scopes = append(scopes, s)
+	}
+
+	for i := len(scopes) - 1; i >= 0; i-- {
+		s := scopes[i]
+		fields := s.fields[name]
+		if len(fields) == 0 {
+			continue
+		}
+
+		doc := make(map[string]interface{})
+		for key, v := range fields {
+			setPath(doc, strings.Split(key, "."), v)
+		}
+		// normalize values to config file form, decoded the same as it
+		buf := bytes.Buffer{}
+		if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+			return err
+		}
+		var sec map[string]interface{}
+		if _, err := toml.Decode(buf.String(), &sec); err != nil {
+			return err
+		}
+		md, err := decodeSection(name, sec, op)
+		if err != nil {
+			return err
+		}
+
+		undecoded := make(map[string]bool)
+		for _, key := range md.Undecoded() {
+			undecoded[key.String()] = true
+		}
+		for key := range fields {
+			if !undecoded[key] {
+				s.used[name+"."+key] = true
+				src.fields[key] = Provenance{Kind: SourceOverride}
+			}
+		}
+	}
+	return nil
+}
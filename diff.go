@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ChangedFields returns keys of fields differ between two options, in
+// "Field.Sub" form as in config file, returns nil if they are equal.
+//
+// Compares exported fields structurally, fields ignored by toml tag `-` and
+// unexported fields are skipped, so they can hold derived or cached values.
+// A value with method `Equal(T) bool` of its own type, such as time.Time, is
+// compared by that method, value of type has TypeHook is compared by its
+// encoded value. Func, chan and unsafe pointer values are ignored.
+func ChangedFields(op1, op2 Option) []string {
+	d := differ{visited: make(map[visit]bool)}
+	d.diff("", reflect.ValueOf(op1), reflect.ValueOf(op2))
+	return d.keys
+}
+
+func optionChanged(op1, op2 Option) bool {
+	return len(ChangedFields(op1, op2)) != 0
+}
+
+// valueChanged returns true if two field values differ, compared the same as
+// ChangedFields().
+func valueChanged(v1, v2 reflect.Value) bool {
+	d := differ{visited: make(map[visit]bool)}
+	d.diff("", v1, v2)
+	return len(d.keys) != 0
+}
+
+// visit is pair of pointers compared, to stop on reference cycles.
+type visit struct {
+	p1, p2 uintptr
+	t      reflect.Type
+}
+
+type differ struct {
+	keys    []string
+	visited map[visit]bool
+}
+
+func (d *differ) changed(path string) {
+	d.keys = append(d.keys, path)
+}
+
+func (d *differ) diff(path string, v1, v2 reflect.Value) {
+	if !v1.IsValid() || !v2.IsValid() {
+		if v1.IsValid() != v2.IsValid() {
+			d.changed(path)
+		}
+		return
+	}
+	if v1.Type() != v2.Type() {
+		d.changed(path)
+		return
+	}
+
+	switch v1.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v1.IsNil() || v2.IsNil() {
+			if v1.IsNil() != v2.IsNil() && !isEmptyCollection(v1, v2) {
+				d.changed(path)
+			}
+			return
+		}
+	}
+
+	if eq, ok := callEqual(v1, v2); ok {
+		if !eq {
+			d.changed(path)
+		}
+		return
+	}
+	if eq, ok := hookEqual(v1, v2); ok {
+		if !eq {
+			d.changed(path)
+		}
+		return
+	}
+
+	switch v1.Kind() {
+	case reflect.Ptr:
+		v := visit{v1.Pointer(), v2.Pointer(), v1.Type()}
+		if v.p1 == v.p2 || d.visited[v] {
+			return
+		}
+		d.visited[v] = true
+		d.diff(path, v1.Elem(), v2.Elem())
+	case reflect.Interface:
+		d.diff(path, v1.Elem(), v2.Elem())
+	case reflect.Struct:
+		for _, f := range structFields(v1.Type()) {
+			d.diff(joinKey(path, f.key), fieldByIndex(v1, f.index, false), fieldByIndex(v2, f.index, false))
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value, v1.Len())
+		for _, k := range append(v1.MapKeys(), v2.MapKeys()...) {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k := keys[name]
+			d.diff(joinKey(path, name), v1.MapIndex(k), v2.MapIndex(k))
+		}
+	case reflect.Slice, reflect.Array:
+		if v1.Len() != v2.Len() {
+			d.changed(path)
+			return
+		}
+		for i := 0; i < v1.Len(); i++ {
+			if valueChanged(v1.Index(i), v2.Index(i)) {
+				d.changed(path)
+				return
+			}
+		}
+	case reflect.Bool:
+		if v1.Bool() != v2.Bool() {
+			d.changed(path)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v1.Int() != v2.Int() {
+			d.changed(path)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v1.Uint() != v2.Uint() {
+			d.changed(path)
+		}
+	case reflect.Float32, reflect.Float64:
+		if v1.Float() != v2.Float() {
+			d.changed(path)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if v1.Complex() != v2.Complex() {
+			d.changed(path)
+		}
+	case reflect.String:
+		if v1.String() != v2.String() {
+			d.changed(path)
+		}
+	}
+}
+
+// isEmptyCollection returns true if v1 and v2 are nil or empty maps or
+// slices, they are the same in config file.
+func isEmptyCollection(v1, v2 reflect.Value) bool {
+	switch v1.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v1.Len() == 0 && v2.Len() == 0
+	}
+	return false
+}
+
+// callEqual calls `Equal(T) bool` method of v1 if exist and callable, returns
+// false as the second result if not.
+func callEqual(v1, v2 reflect.Value) (bool, bool) {
+	if !v1.CanInterface() {
+		return false, false
+	}
+	m, ok := v1.Type().MethodByName("Equal")
+	if !ok {
+		return false, false
+	}
+
+	mt := m.Type
+	if mt.NumIn() != 2 || mt.In(1) != v1.Type() || mt.NumOut() != 1 || mt.Out(0).Kind() != reflect.Bool {
+		return false, false
+	}
+	return m.Func.Call([]reflect.Value{v1, v2})[0].Bool(), true
+}
+
+// hookEqual compares values of hooked type by their encoded values, such as
+// *regexp.Regexp without exported fields. Returns false as the second result
+// if not hooked or encode failed.
+func hookEqual(v1, v2 reflect.Value) (bool, bool) {
+	hook, ok := typeHooks[v1.Type()]
+	if !ok || !v1.CanInterface() {
+		return false, false
+	}
+
+	e1, err := hook.Encode(v1.Interface())
+	if err != nil {
+		return false, false
+	}
+	e2, err := hook.Encode(v2.Interface())
+	if err != nil {
+		return false, false
+	}
+	return reflect.DeepEqual(e1, e2), true
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,126 @@
+package config_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type diffInner struct {
+	Host string
+	Port int
+}
+
+// caseInsensitive equals ignoring case.
+type caseInsensitive string
+
+func (s caseInsensitive) Equal(other caseInsensitive) bool {
+	return strings.EqualFold(string(s), string(other))
+}
+
+type diffOption struct {
+	Name    string
+	Start   time.Time
+	DB      diffInner
+	Peer    *diffInner
+	Tags    []string
+	Limits  map[string]int
+	User    caseInsensitive
+	Derived string `toml:"-"`
+	OnError func()
+	Next    *diffOption
+
+	cache int
+}
+
+func (o *diffOption) Init() error {
+	return nil
+}
+
+func (o *diffOption) Apply() {
+}
+
+var _ = Describe("ChangedFields", func() {
+
+	var (
+		base time.Time
+		op   = func() *diffOption {
+			return &diffOption{
+				Name:   "foo",
+				Start:  base,
+				DB:     diffInner{"localhost", 80},
+				Peer:   &diffInner{"peer", 81},
+				Tags:   []string{"a", "b"},
+				Limits: map[string]int{"a": 1},
+				User:   "admin",
+			}
+		}
+	)
+
+	BeforeEach(func() {
+		base = time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	})
+
+	It("Equal", func() {
+		Ω(ChangedFields(op(), op())).Should(BeEmpty())
+	})
+
+	It("Changed", func() {
+		a, b := op(), op()
+		b.Name = "bar"
+		b.DB.Port = 90
+		b.Peer.Host = "other"
+		b.Tags[1] = "c"
+		b.Limits["b"] = 2
+		Ω(ChangedFields(a, b)).Should(Equal([]string{"Name", "DB.Port", "Peer.Host", "Tags", "Limits.b"}))
+	})
+
+	It("Nil pointer", func() {
+		a, b := op(), op()
+		b.Peer = nil
+		Ω(ChangedFields(a, b)).Should(Equal([]string{"Peer"}))
+	})
+
+	It("Nil and empty collections are equal", func() {
+		a, b := op(), op()
+		a.Tags, b.Tags = nil, []string{}
+		a.Limits, b.Limits = nil, map[string]int{}
+		Ω(ChangedFields(a, b)).Should(BeEmpty())
+	})
+
+	It("Equal method", func() {
+		a, b := op(), op()
+		b.Start = base.In(time.FixedZone("X", 3600))
+		b.User = "ADMIN"
+		Ω(ChangedFields(a, b)).Should(BeEmpty())
+	})
+
+	It("Ignored fields", func() {
+		a, b := op(), op()
+		b.Derived = "x"
+		b.OnError = func() {}
+		b.cache = 3
+		Ω(ChangedFields(a, b)).Should(BeEmpty())
+	})
+
+	It("Reference cycle", func() {
+		a, b := op(), op()
+		a.Next, b.Next = a, b
+		Ω(ChangedFields(a, b)).Should(BeEmpty())
+
+		b.Name = "bar"
+		Ω(ChangedFields(a, b)).Should(Equal([]string{"Name"}))
+	})
+
+	It("Option map", func() {
+		a := OptionMap{"x": op(), "y": op()}
+		b := OptionMap{"x": op(), "z": op()}
+		b["x"].(*diffOption).Name = "bar"
+		Ω(ChangedFields(a, b)).Should(Equal([]string{"x.Name", "y", "z"}))
+	})
+
+})
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Document is parsed content of config file. Each top level key is an option
+// name or the first segment of hierarchical option names, tables are
+// map[string]interface{}.
+type Document map[string]interface{}
+
+// Section returns table of option name, hierarchical name such as
+// "db.primary" is table nested in table "db". Returns nil if not exist.
+// Returns error if name or its parent exist but not a table.
+func (doc Document) Section(name string) (map[string]interface{}, error) {
+	m := map[string]interface{}(doc)
+	path := splitName(name)
+	for i, p := range path {
+		v, ok := m[p]
+		if !ok {
+			return nil, nil
+		}
+
+		if m, ok = v.(map[string]interface{}); !ok {
+			key := strings.Join(path[:i+1], ".")
+			return nil, keyErrorf(key, "[%s] '%s' in config file should be a table", tag, key)
+		}
+	}
+	return m, nil
+}
+
+// keyError is an error about a key in config file, suffixed by its location
+// in file if known.
+type keyError struct {
+	key string
+	msg string
+	loc string
+}
+
+func keyErrorf(key, format string, args ...interface{}) *keyError {
+	return &keyError{key: key, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *keyError) Error() string {
+	return e.msg + e.loc
+}
+
+// locateError adds location of key in config file to keyError.
+func locateError(err error, file *fileSource) error {
+	if e, ok := err.(*keyError); ok && e.loc == "" {
+		e.loc = file.locate(e.key)
+	}
+	return err
+}
+
+// setSection set table of option name, creates parent tables if not exist.
+func (doc Document) setSection(name string, sec map[string]interface{}) {
+	setPath(doc, splitName(name), sec)
+}
+
+// deleteSection removes table of option name, and its parent tables if they
+// become empty.
+func (doc Document) deleteSection(name string) {
+	deletePath(doc, splitName(name))
+}
+
+func deletePath(m map[string]interface{}, path []string) {
+	if len(path) > 1 {
+		sub, ok := m[path[0]].(map[string]interface{})
+		if !ok {
+			return
+		}
+		deletePath(sub, path[1:])
+		if len(sub) != 0 {
+			return
+		}
+	}
+	delete(m, path[0])
+}
+
+// splitName split hierarchical option name into segments.
+func splitName(name string) []string {
+	return strings.Split(name, ".")
+}
+
+// readDocument read and parse config file, checked by permission policy and
+// verified by verifier if set. Returned fileSource locates keys in the file.
+func readDocument(path string) (Document, *fileSource, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkFilePerm(path); err != nil {
+		return nil, nil, err
+	}
+
+	if verifier != nil {
+		if err = verifier.Verify(path, data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var doc Document
+	if _, err = toml.Decode(string(data), &doc); err != nil {
+		return nil, nil, err
+	}
+	return doc, newFileSource(path, data), nil
+}
+
+// decodeDocument decode doc into opts, opts are in options order. Returns keys
+// in doc not decoded to any option.
+func decodeDocument(doc Document, opts []Option) ([]string, error) {
+	var unknown []string
+	for i, rec := range options {
+		keys, err := decodeOption(doc, rec, opts[i])
+		if err != nil {
+			return nil, err
+		}
+		unknown = append(unknown, keys...)
+	}
+
+	unknown = append(unknown, unknownTables(doc, "")...)
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// decodeOption decode table of rec in doc into op, returns keys in the table
+// not decoded.
+func decodeOption(doc Document, rec *optionRec, op Option) ([]string, error) {
+	sec, err := doc.Section(rec.name)
+	if err != nil || sec == nil {
+		return nil, err
+	}
+
+	if rec.item != nil {
+		return decodeMap(rec, sec, op.(OptionMap))
+	}
+
+	md, err := decodeSection(rec.name, sec, op)
+	if err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for _, key := range md.Undecoded() {
+		unknown = append(unknown, rec.name+"."+key.String())
+	}
+	return unknown, nil
+}
+
+// unknownTables returns keys of m not an option table nor parent table of
+// hierarchical option names, prefix is the dotted path of m.
+func unknownTables(m map[string]interface{}, prefix string) []string {
+	var r []string
+	for k, v := range m {
+		name := prefix + k
+		if findOption(name) != nil {
+			continue
+		}
+
+		if sub, ok := v.(map[string]interface{}); ok && isParentName(name) {
+			r = append(r, unknownTables(sub, name+".")...)
+			continue
+		}
+		r = append(r, name)
+	}
+	return r
+}
+
+// isParentName returns true if name is parent of any hierarchical option
+// names, such as "db" is parent of "db.primary".
+func isParentName(name string) bool {
+	for _, rec := range options {
+		if strings.HasPrefix(rec.name, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// nestOptions converts option name to option map to nested tables, so that
+// hierarchical option names encoded as nested tables. Options are converted
+// by encodeOption().
+func nestOptions(opts map[string]Option) (map[string]interface{}, error) {
+	r := make(map[string]interface{}, len(opts))
+	for name, op := range opts {
+		v, err := encodeOption(op)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] encode option '%s': %s", tag, name, err)
+		}
+		setPath(r, splitName(name), v)
+	}
+	return r, nil
+}
+
+// optionDocument convert option to table in config document form.
+func optionDocument(op Option) (map[string]interface{}, error) {
+	v, err := encodeOption(op)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	if err = toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	var r map[string]interface{}
+	if _, err := toml.Decode(buf.String(), &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// walkStrings replace each string value inside v by fn in place, path is key
+// of v in "option.Key" form. Returns replaced v.
+func walkStrings(v interface{}, path string, fn func(path, s string) (string, error)) (interface{}, error) {
+	var err error
+	switch val := v.(type) {
+	case string:
+		return fn(path, val)
+	case map[string]interface{}:
+		for k, item := range val {
+			if val[k], err = walkStrings(item, path+"."+k, fn); err != nil {
+				return nil, err
+			}
+		}
+	case []map[string]interface{}:
+		for _, item := range val {
+			if _, err = walkStrings(item, path, fn); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			if val[i], err = walkStrings(item, path, fn); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// walkSections calls walkStrings on each registered option table in doc.
+func walkSections(doc Document, fn func(path, s string) (string, error)) error {
+	for _, rec := range options {
+		sec, err := doc.Section(rec.name)
+		if err != nil {
+			return err
+		}
+		if sec == nil {
+			continue
+		}
+		if _, err = walkStrings(sec, rec.name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPath set value at path in m, creates intermediate tables if not exist,
+// replaces non-table intermediate values.
+func setPath(m map[string]interface{}, path []string, v interface{}) {
+	for _, p := range path[:len(path)-1] {
+		sub, ok := m[p].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[p] = sub
+		}
+		m = sub
+	}
+	m[path[len(path)-1]] = v
+}
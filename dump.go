@@ -3,16 +3,25 @@ package config
 import (
 	"bufio"
 	"bytes"
+	"encoding"
+	"fmt"
 	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/redforks/appinfo"
+	"github.com/redforks/errors"
 )
 
 func getDefaultOptionKVs() map[string]Option {
 	opts := make(map[string]Option, len(options))
-	for _, rec := range options {
-		opts[rec.name] = rec.creator()
+	for i, op := range newOptions() {
+		opts[options[i].name] = op
 	}
 	return opts
 }
@@ -35,7 +44,13 @@ func commentOutAll(prefix string, reader io.Reader) string {
 // DumpDefaultOptions dump default options in config file format. All options
 // are comment out.
 func DumpDefaultOptions() (string, error) {
-	opts := getDefaultOptionKVs()
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	opts, err := nestOptions(getDefaultOptionKVs())
+	if err != nil {
+		return "", err
+	}
 
 	buf := bytes.Buffer{}
 	encoder := toml.NewEncoder(&buf)
@@ -46,3 +61,274 @@ func DumpDefaultOptions() (string, error) {
 
 	return commentOutAll("# default options for "+appinfo.CodeName()+"\n\n", &buf), nil
 }
+
+func getEffectiveOptionKVs() (map[string]Option, error) {
+	opts := make(map[string]Option, len(options))
+	for _, rec := range options {
+		if rec.op == nil {
+			if inited {
+				// registered by RegisterLate(), being inited
+				continue
+			}
+			return nil, errors.Bugf("[%s] option '%s' not inited", tag, rec.name)
+		}
+		opts[rec.name] = rec.op
+	}
+	return opts, nil
+}
+
+// DumpEffectiveOptions dump options currently in effect in config file format,
+// options must be inited by Load() or life.Start(). Restart-required options
+// changed since start are listed in the header comment, values not from
+// option defaults are followed by comment of their provenance.
+func DumpEffectiveOptions() (string, error) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	kvs, err := getEffectiveOptionKVs()
+	if err != nil {
+		return "", err
+	}
+	opts, err := nestOptions(kvs)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+	_, _ = buf.WriteString("# effective options for " + appinfo.CodeName() + "\n")
+	v := CurrentVersion()
+	_, _ = fmt.Fprintf(&buf, "# version: generation %d, sha256 %s\n", v.Generation, v.Hash)
+	if p := ActiveProfile(); p != "" {
+		_, _ = buf.WriteString("# profile: " + p + "\n")
+	}
+	if len(restartPending) != 0 {
+		_, _ = buf.WriteString("# restart pending: " + strings.Join(restartPending, ", ") + "\n")
+	}
+	_, _ = buf.WriteRune('\n')
+
+	d := dumper{buf: &buf}
+	if err := d.table(nil, "", reflect.ValueOf(opts)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// dumper writes options in config file format the same as toml package,
+// each key whose value not from option default followed by comment of its
+// provenance.
+type dumper struct {
+	buf     *bytes.Buffer
+	written bool // anything written after header
+}
+
+// dumpEntry is a key and its value in a table.
+type dumpEntry struct {
+	key string
+	v   reflect.Value
+}
+
+// table writes keys of table v, path is its keys, key is its dotted key of
+// provenance, elements of arrays of tables indexed. Plain values written
+// first, then sub tables and arrays of tables, so that they do not capture
+// plain values.
+func (d *dumper) table(path []string, key string, v reflect.Value) error {
+	var direct, sub []dumpEntry
+	for _, e := range tableEntries(indirect(v)) {
+		if isTable(e.v) || isTableArray(e.v) {
+			sub = append(sub, e)
+		} else {
+			direct = append(direct, e)
+		}
+	}
+
+	for _, e := range direct {
+		if err := d.value(joinKey(key, e.key), e); err != nil {
+			return err
+		}
+	}
+	for _, e := range sub {
+		subPath := append(path[:len(path):len(path)], e.key)
+		header := tableHeader(subPath)
+		if isTable(e.v) {
+			if len(path) == 0 {
+				d.newline()
+			}
+			d.line("[" + header + "]")
+			if err := d.table(subPath, joinKey(key, e.key), e.v); err != nil {
+				return err
+			}
+			continue
+		}
+
+		items := indirect(e.v)
+		for i := 0; i < items.Len(); i++ {
+			item := items.Index(i)
+			if isNilValue(item) {
+				continue
+			}
+			d.newline()
+			d.line("[[" + header + "]]")
+			if err := d.table(subPath, joinKey(key, e.key)+"."+strconv.Itoa(i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// value writes key = value line of e, value encoded by toml package, key is
+// dotted key of provenance of e.
+func (d *dumper) value(key string, e dumpEntry) error {
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(map[string]interface{}{e.key: e.v.Interface()}); err != nil {
+		return err
+	}
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	if p, ok := keyProvenance(key); ok && p.Kind != SourceDefault {
+		line += " # " + p.String()
+	}
+	d.line(line)
+	return nil
+}
+
+func (d *dumper) line(s string) {
+	_, _ = d.buf.WriteString(s + "\n")
+	d.written = true
+}
+
+// newline writes an empty line between tables, not before the first one.
+func (d *dumper) newline() {
+	if d.written {
+		_, _ = d.buf.WriteRune('\n')
+	}
+}
+
+// tableEntries returns non-nil entries of table v, struct fields in their
+// order, map keys sorted.
+func tableEntries(v reflect.Value) []dumpEntry {
+	var r []dumpEntry
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			r = append(r, dumpEntry{k.String(), v.MapIndex(k)})
+		}
+		sort.Slice(r, func(i, j int) bool { return r[i].key < r[j].key })
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			fv := fieldByIndex(v, f.index, false)
+			if !fv.IsValid() || omitField(fv, f.opts) {
+				continue
+			}
+			r = append(r, dumpEntry{f.key, fv})
+		}
+	}
+
+	n := 0
+	for _, e := range r {
+		if !isNilValue(e.v) {
+			r[n] = e
+			n++
+		}
+	}
+	return r[:n]
+}
+
+// omitField returns true if field value v omitted by toml tag options opts,
+// the same as toml package.
+func omitField(v reflect.Value, opts string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		switch opt {
+		case "omitempty":
+			switch v.Kind() {
+			case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+				if v.Len() == 0 {
+					return true
+				}
+			}
+		case "omitzero":
+			switch v.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				if v.Int() == 0 {
+					return true
+				}
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				if v.Uint() == 0 {
+					return true
+				}
+			case reflect.Float32, reflect.Float64:
+				if v.Float() == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// indirect returns value v points to, through pointers and interfaces.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isTable returns true if v encoded as table, time and text marshaler structs
+// are plain values.
+func isTable(v reflect.Value) bool {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		switch v.Interface().(type) {
+		case time.Time, encoding.TextMarshaler:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// isTableArray returns true if v is non-empty array of tables.
+func isTableArray(v reflect.Value) bool {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		return v.Len() != 0 && isTable(v.Index(0))
+	}
+	return false
+}
+
+// tableHeader returns dotted table name of path, keys not bare are quoted.
+func tableHeader(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = k
+		if !bareKey.MatchString(k) {
+			keys[i] = strconv.Quote(k)
+		}
+	}
+	return strings.Join(keys, ".")
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// keyProvenance returns provenance of key in "option.Key" form.
+func keyProvenance(key string) (Provenance, bool) {
+	for _, rec := range options {
+		if rec.sources != nil && strings.HasPrefix(key, rec.name+".") {
+			return rec.sources.lookup(key[len(rec.name)+1:]), true
+		}
+	}
+	return Provenance{}, false
+}
@@ -1,6 +1,6 @@
 module github.com/redforks/config
 
-go 1.13
+go 1.21
 
 require (
 	github.com/BurntSushi/toml v0.3.1
@@ -14,3 +14,18 @@ require (
 	github.com/redforks/xdgdirs v1.0.1
 	github.com/urfave/cli v1.22.2
 )
+
+require (
+	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
+	github.com/hpcloud/tail v1.0.0 // indirect
+	github.com/redforks/osutil v1.0.0 // indirect
+	github.com/russross/blackfriday/v2 v2.0.1 // indirect
+	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/stevenle/topsort v0.0.0-20130922064739-8130c1d7596b // indirect
+	golang.org/x/net v0.0.0-20180906233101-161cd47e91fd // indirect
+	golang.org/x/sys v0.0.0-20180909124046-d0be0721c37e // indirect
+	golang.org/x/text v0.3.0 // indirect
+	gopkg.in/fsnotify.v1 v1.4.7 // indirect
+	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
+	gopkg.in/yaml.v2 v2.2.4 // indirect
+)
@@ -0,0 +1,672 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/redforks/testing/reset"
+)
+
+// TypeHook converts values of a type between config file and option fields,
+// for types not directly supported by config file format, such as
+// time.Duration written as "1m30s".
+type TypeHook struct {
+	// Decode converts value in config file, such as string or int64, to value
+	// of the type.
+	Decode func(v interface{}) (interface{}, error)
+
+	// Encode converts value of the type to value in config file, used by
+	// dumps, option version hash and default values of interpolation.
+	Encode func(v interface{}) (interface{}, error)
+}
+
+// typeHooks registered by RegisterTypeHook(), and built-in hooks.
+var typeHooks = make(map[reflect.Type]TypeHook)
+
+// RegisterTypeHook registers hook of type t, replaces previous hook of t,
+// including built-in ones. Fields of type t, and slices, maps, pointers of t
+// are converted by hook. Hooks registered in unit tests are undone by
+// spork/testing/reset package, hooks registered in init() are kept.
+//
+// Built-in hooks:
+//
+//	time.Duration    "1m30s", or integer of nanoseconds
+//	ByteSize         "10MB", "1.5GiB", or integer of bytes
+//	*url.URL         "https://example.com/path"
+//	net.IP           "127.0.0.1", "::1"
+//	*regexp.Regexp   "^[a-z]+$"
+//	Level            "debug", "info", "warn", "error"
+func RegisterTypeHook(t reflect.Type, hook TypeHook) {
+	if hook.Decode == nil || hook.Encode == nil {
+		log.Panicf("[%s] type hook of %s must have both Decode and Encode", tag, t)
+	}
+	old, exist := typeHooks[t]
+	reset.Add(func() {
+		if exist {
+			typeHooks[t] = old
+		} else {
+			delete(typeHooks, t)
+		}
+	})
+	typeHooks[t] = hook
+}
+
+func registerBuiltinHooks() {
+	RegisterTypeHook(reflect.TypeOf(time.Duration(0)), TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			if n, ok := v.(int64); ok {
+				return time.Duration(n), nil
+			}
+			s, err := hookString(v)
+			if err != nil {
+				return nil, err
+			}
+			return time.ParseDuration(s)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(time.Duration).String(), nil
+		},
+	})
+
+	RegisterTypeHook(reflect.TypeOf(ByteSize(0)), TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			if n, ok := v.(int64); ok {
+				return ByteSize(n), nil
+			}
+			s, err := hookString(v)
+			if err != nil {
+				return nil, err
+			}
+			return ParseByteSize(s)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(ByteSize).String(), nil
+		},
+	})
+
+	RegisterTypeHook(reflect.TypeOf((*url.URL)(nil)), TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			s, err := hookString(v)
+			if err != nil {
+				return nil, err
+			}
+			return url.Parse(s)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(*url.URL).String(), nil
+		},
+	})
+
+	RegisterTypeHook(reflect.TypeOf(net.IP(nil)), TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			s, err := hookString(v)
+			if err != nil {
+				return nil, err
+			}
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address '%s'", s)
+			}
+			return ip, nil
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(net.IP).String(), nil
+		},
+	})
+
+	RegisterTypeHook(reflect.TypeOf((*regexp.Regexp)(nil)), TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			s, err := hookString(v)
+			if err != nil {
+				return nil, err
+			}
+			return regexp.Compile(s)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(*regexp.Regexp).String(), nil
+		},
+	})
+
+	RegisterTypeHook(reflect.TypeOf(Level(0)), TypeHook{
+		Decode: func(v interface{}) (interface{}, error) {
+			s, err := hookString(v)
+			if err != nil {
+				return nil, err
+			}
+			return ParseLevel(s)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return strings.ToLower(v.(Level).String()), nil
+		},
+	})
+}
+
+func hookString(v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expect string, got %T", v)
+	}
+	return s, nil
+}
+
+// hasHook returns true if values of t or values inside t converted by hooks.
+func hasHook(t reflect.Type) bool {
+	return typeHasHook(t, make(map[reflect.Type]bool))
+}
+
+func typeHasHook(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if _, ok := typeHooks[t]; ok {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeHasHook(t.Elem(), seen)
+	case reflect.Struct:
+		for _, f := range structFields(t) {
+			if typeHasHook(f.typ, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fullyHooked returns true if values of t are decoded by hooks as a whole,
+// such as hooked types and slices of hooked types.
+func fullyHooked(t reflect.Type) bool {
+	if _, ok := typeHooks[t]; ok {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return fullyHooked(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && fullyHooked(t.Elem())
+	}
+	return false
+}
+
+// hookField is a struct field in config file, fields of embedded structs are
+// flattened the same as toml package.
+type hookField struct {
+	index []int
+	key   string
+	opts  string // toml tag options, such as ",omitempty"
+	typ   reflect.Type
+}
+
+func structFields(t reflect.Type) []hookField {
+	var r []hookField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tomlTag := f.Tag.Get("toml")
+		name := strings.Split(tomlTag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, sub := range structFields(ft) {
+					sub.index = append([]int{i}, sub.index...)
+					r = append(r, sub)
+				}
+				continue
+			}
+		}
+
+		key, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		opts := ""
+		if n := strings.Index(tomlTag, ","); n >= 0 {
+			opts = tomlTag[n:]
+		}
+		r = append(r, hookField{[]int{i}, key, opts, f.Type})
+	}
+	return r
+}
+
+// fieldByIndex returns field of struct v by index, allocates nil embedded
+// struct pointers if alloc, otherwise returns invalid Value on nil pointer.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// findKey returns key in m matches field key, case insensitive the same as
+// toml package if no exact match.
+func findKey(m map[string]interface{}, key string) (string, bool) {
+	if _, ok := m[key]; ok {
+		return key, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// hookSetter sets values removed by splitHooked() to v.
+type hookSetter func(v reflect.Value) error
+
+// splitHooked removes values of hooked types from raw, the config document
+// value to decode to type t, so that the rest can be decoded by toml. The
+// returned setter decodes removed values by hooks and sets them to the
+// decoded value, nil if nothing removed. path is key of raw in error message.
+func splitHooked(t reflect.Type, raw interface{}, path string) (hookSetter, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		set, err := splitHooked(t.Elem(), raw, path)
+		if set == nil || err != nil {
+			return set, err
+		}
+		return func(v reflect.Value) error {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			return set(v.Elem())
+		}, nil
+
+	case reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			// let toml report type mismatch
+			return nil, nil
+		}
+
+		var sets []hookSetter
+		for _, f := range structFields(t) {
+			if !hasHook(f.typ) {
+				continue
+			}
+			k, ok := findKey(m, f.key)
+			if !ok {
+				continue
+			}
+
+			f, key, item := f, joinKey(path, f.key), m[k]
+			if fullyHooked(f.typ) {
+				delete(m, k)
+				sets = append(sets, func(v reflect.Value) error {
+					fv, err := decodeHooked(f.typ, item, key)
+					if err != nil {
+						return err
+					}
+					fieldByIndex(v, f.index, true).Set(fv)
+					return nil
+				})
+				continue
+			}
+
+			set, err := splitHooked(f.typ, item, key)
+			if err != nil {
+				return nil, err
+			}
+			if set != nil {
+				sets = append(sets, func(v reflect.Value) error {
+					return set(fieldByIndex(v, f.index, true))
+				})
+			}
+		}
+		return joinSetters(sets), nil
+
+	case reflect.Slice:
+		items, ok := raw.([]map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+
+		sets := make([]hookSetter, len(items))
+		found := false
+		for i, item := range items {
+			set, err := splitHooked(t.Elem(), item, path)
+			if err != nil {
+				return nil, err
+			}
+			sets[i], found = set, found || set != nil
+		}
+		if !found {
+			return nil, nil
+		}
+		return func(v reflect.Value) error {
+			for i, set := range sets {
+				if set == nil || i >= v.Len() {
+					continue
+				}
+				if err := set(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+
+	case reflect.Map:
+		m, ok := raw.(map[string]interface{})
+		if !ok || t.Key().Kind() != reflect.String {
+			return nil, nil
+		}
+
+		sets := make(map[string]hookSetter)
+		for k, item := range m {
+			set, err := splitHooked(t.Elem(), item, joinKey(path, k))
+			if err != nil {
+				return nil, err
+			}
+			if set != nil {
+				sets[k] = set
+			}
+		}
+		if len(sets) == 0 {
+			return nil, nil
+		}
+		return func(v reflect.Value) error {
+			for k, set := range sets {
+				key := reflect.ValueOf(k).Convert(t.Key())
+				item := v.MapIndex(key)
+				if !item.IsValid() {
+					continue
+				}
+
+				// map items are not addressable, set a copy and put back
+				cp := reflect.New(t.Elem()).Elem()
+				cp.Set(item)
+				if err := set(cp); err != nil {
+					return err
+				}
+				v.SetMapIndex(key, cp)
+			}
+			return nil
+		}, nil
+	}
+	return nil, nil
+}
+
+func joinSetters(sets []hookSetter) hookSetter {
+	if len(sets) == 0 {
+		return nil
+	}
+	return func(v reflect.Value) error {
+		for _, set := range sets {
+			if err := set(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// decodeHooked decodes raw to value of fully hooked type t.
+func decodeHooked(t reflect.Type, raw interface{}, path string) (reflect.Value, error) {
+	if hook, ok := typeHooks[t]; ok {
+		v, err := hook.Decode(raw)
+		if err != nil {
+			return reflect.Value{}, keyErrorf(path, "[%s] decode '%s': %s", tag, path, err)
+		}
+
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || !rv.Type().ConvertibleTo(t) {
+			return reflect.Value{}, keyErrorf(path, "[%s] decode '%s': hook of %s returns %T", tag, path, t, v)
+		}
+		return rv.Convert(t), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		v, err := decodeHooked(t.Elem(), raw, path)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v)
+		return p, nil
+
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return reflect.Value{}, keyErrorf(path, "[%s] '%s' in config file should be an array", tag, path)
+		}
+		s := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			v, err := decodeHooked(t.Elem(), item, path)
+			if err != nil {
+				return v, err
+			}
+			s.Index(i).Set(v)
+		}
+		return s, nil
+
+	case reflect.Map:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return reflect.Value{}, keyErrorf(path, "[%s] '%s' in config file should be a table", tag, path)
+		}
+		r := reflect.MakeMapWithSize(t, len(m))
+		for k, item := range m {
+			v, err := decodeHooked(t.Elem(), item, joinKey(path, k))
+			if err != nil {
+				return v, err
+			}
+			r.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), v)
+		}
+		return r, nil
+	}
+	return reflect.Value{}, keyErrorf(path, "[%s] decode '%s': no hook of %s", tag, path, t)
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// encodeHooked returns v with values of hooked types encoded, field order of
+// structs kept. Returns v itself if nothing hooked inside.
+func encodeHooked(v reflect.Value) (reflect.Value, error) {
+	t := v.Type()
+	if hook, ok := typeHooks[t]; ok {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			if v.IsNil() {
+				return v, nil
+			}
+		}
+		r, err := hook.Encode(v.Interface())
+		return reflect.ValueOf(r), err
+	}
+	if !hasHook(t) {
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		return encodeHooked(v.Elem())
+
+	case reflect.Struct:
+		fields := structFields(t)
+		sfs := make([]reflect.StructField, len(fields))
+		vals := make([]reflect.Value, len(fields))
+		for i, f := range fields {
+			sfs[i] = reflect.StructField{
+				Name: fmt.Sprintf("F%d", i),
+				Type: f.typ,
+				Tag:  reflect.StructTag(fmt.Sprintf(`toml:"%s%s"`, f.key, f.opts)),
+			}
+			fv := fieldByIndex(v, f.index, false)
+			if fv.IsValid() && hasHook(f.typ) {
+				var err error
+				if fv, err = encodeHooked(fv); err != nil {
+					return fv, err
+				}
+				sfs[i].Type = interfaceType
+			}
+			vals[i] = fv
+		}
+
+		r := reflect.New(reflect.StructOf(sfs)).Elem()
+		for i, fv := range vals {
+			if fv.IsValid() {
+				r.Field(i).Set(fv)
+			}
+		}
+		return r, nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return v, nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := encodeHooked(v.Index(i))
+			if err != nil {
+				return item, err
+			}
+			if item.IsValid() {
+				items[i] = item.Interface()
+			}
+		}
+		return reflect.ValueOf(items), nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			item, err := encodeHooked(v.MapIndex(k))
+			if err != nil {
+				return item, err
+			}
+			if item.IsValid() {
+				m[fmt.Sprint(k.Interface())] = item.Interface()
+			}
+		}
+		return reflect.ValueOf(m), nil
+	}
+	return v, nil
+}
+
+// encodeOption returns op in the form to encode to config file, values of
+// hooked types encoded by their hooks.
+func encodeOption(op Option) (interface{}, error) {
+	v, err := encodeHooked(reflect.ValueOf(op))
+	if err != nil || !v.IsValid() {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// decodeSection decodes option table sec into op by toml package, values of
+// hooked types are decoded by their hooks, sec is changed. Returns toml meta
+// data.
+func decodeSection(name string, sec map[string]interface{}, op Option) (toml.MetaData, error) {
+	set, err := splitHooked(reflect.TypeOf(op), sec, name)
+	if err != nil {
+		return toml.MetaData{}, err
+	}
+
+	buf := bytes.Buffer{}
+	if err = toml.NewEncoder(&buf).Encode(sec); err != nil {
+		return toml.MetaData{}, err
+	}
+	md, err := toml.Decode(buf.String(), op)
+	if err != nil {
+		return md, keyErrorf(name, "[%s] decode option '%s': %s", tag, name, err)
+	}
+
+	if set != nil {
+		err = set(reflect.ValueOf(op))
+	}
+	return md, err
+}
+
+// ByteSize is a size in bytes. In config file it is integer of bytes, or
+// string with unit such as "10MB", "1.5GiB". KB, MB, GB, TB, PB are powers of
+// 1000, KiB, MiB, GiB, TiB, PiB are powers of 1024, units are case
+// insensitive.
+type ByteSize int64
+
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"PiB", 1 << 50}, {"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"PB", 1e15}, {"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// ParseByteSize parses size string such as "10MB", "512KiB" or "100".
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.TrimSpace(s)
+	n := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if n < 0 {
+		n = len(str)
+	}
+
+	num, unit := str[:n], strings.TrimSpace(str[n:])
+	var size int64 = 1
+	if unit != "" {
+		size = 0
+		for _, u := range byteUnits {
+			if strings.EqualFold(u.name, unit) {
+				size = u.size
+				break
+			}
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("invalid byte size '%s'", s)
+	}
+	v := f * float64(size)
+	if v >= math.MaxInt64 {
+		return 0, fmt.Errorf("byte size '%s' overflow", s)
+	}
+	return ByteSize(math.Round(v)), nil
+}
+
+// String returns size in the largest unit divides it exactly, such as
+// "10MB", "512KiB", "100B".
+func (s ByteSize) String() string {
+	for _, u := range byteUnits {
+		if s != 0 && int64(s)%u.size == 0 {
+			return fmt.Sprintf("%d%s", int64(s)/u.size, u.name)
+		}
+	}
+	return "0B"
+}
+
+func init() {
+	registerBuiltinHooks()
+}
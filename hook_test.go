@@ -0,0 +1,254 @@
+package config_test
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/reset"
+)
+
+type hookBackend struct {
+	Timeout time.Duration
+}
+
+type HookEmbedded struct {
+	Retry time.Duration
+}
+
+// color is a custom type decoded by user registered hook.
+type color int
+
+type hookOption struct {
+	HookEmbedded
+
+	Timeout   time.Duration
+	MaxBody   ByteSize
+	Endpoint  *url.URL
+	Listen    net.IP
+	Pattern   *regexp.Regexp
+	LogLevel  Level
+	Intervals []time.Duration
+	Quotas    map[string]ByteSize
+	Primary   hookBackend
+	Backends  []hookBackend
+	Color     color
+	Name      string
+}
+
+func (o *hookOption) Init() error {
+	return nil
+}
+
+func (o *hookOption) Apply() {
+}
+
+func newHookOption() Option {
+	u, _ := url.Parse("http://localhost/api")
+	return &hookOption{
+		HookEmbedded: HookEmbedded{time.Second},
+		Timeout:      30 * time.Second,
+		MaxBody:      10 << 20,
+		Endpoint:     u,
+		Listen:       net.ParseIP("127.0.0.1"),
+		Pattern:      regexp.MustCompile("^[a-z]+$"),
+		LogLevel:     LevelInfo,
+		Intervals:    []time.Duration{time.Second, time.Minute},
+		Quotas:       map[string]ByteSize{"a": 1000},
+		Primary:      hookBackend{time.Hour},
+		Backends:     []hookBackend{{time.Millisecond}},
+		Name:         "foo",
+	}
+}
+
+var _ = Describe("TypeHook", func() {
+
+	var (
+		cfg = setupTestConfig()
+
+		decode = func() (*hookOption, error) {
+			opts, err := DecodeFile(cfg.file)
+			if err != nil {
+				return nil, err
+			}
+			return opts["foo"].(*hookOption), nil
+		}
+	)
+
+	BeforeEach(func() {
+		RegisterTypeHook(reflect.TypeOf(color(0)), TypeHook{
+			Decode: func(v interface{}) (interface{}, error) {
+				switch v {
+				case "none":
+					return color(0), nil
+				case "red":
+					return color(1), nil
+				case "blue":
+					return color(2), nil
+				}
+				return nil, errors.New("unknown color")
+			},
+			Encode: func(v interface{}) (interface{}, error) {
+				return [...]string{"none", "red", "blue"}[v.(color)], nil
+			},
+		})
+		Register("foo", newHookOption)
+	})
+
+	It("Decode", func() {
+		cfg.write(`[foo]
+Retry = "2s"
+Timeout = "1m30s"
+MaxBody = "1.5KiB"
+Endpoint = "https://example.com/v1"
+Listen = "::1"
+Pattern = "^x$"
+LogLevel = "warn"
+Intervals = ["1ms", "2ms"]
+Quotas = {b = "2MB"}
+Color = "blue"
+
+[foo.Primary]
+Timeout = "5s"
+
+[[foo.Backends]]
+Timeout = "1s"
+
+[[foo.Backends]]
+Timeout = "2s"
+`)
+		op, err := decode()
+		Ω(err).Should(Succeed())
+		Ω(op.Retry).Should(Equal(2 * time.Second))
+		Ω(op.Timeout).Should(Equal(90 * time.Second))
+		Ω(op.MaxBody).Should(Equal(ByteSize(1536)))
+		Ω(op.Endpoint.String()).Should(Equal("https://example.com/v1"))
+		Ω(op.Listen.String()).Should(Equal("::1"))
+		Ω(op.Pattern.String()).Should(Equal("^x$"))
+		Ω(op.LogLevel).Should(Equal(LevelWarn))
+		Ω(op.Intervals).Should(Equal([]time.Duration{time.Millisecond, 2 * time.Millisecond}))
+		Ω(op.Quotas).Should(Equal(map[string]ByteSize{"b": 2000000}))
+		Ω(op.Color).Should(Equal(color(2)))
+		Ω(op.Primary).Should(Equal(hookBackend{5 * time.Second}))
+		Ω(op.Backends).Should(Equal([]hookBackend{{time.Second}, {2 * time.Second}}))
+		Ω(op.Name).Should(Equal("foo"))
+	})
+
+	It("Integer duration and size", func() {
+		cfg.write(`[foo]
+Timeout = 1000
+MaxBody = 1024
+`)
+		op, err := decode()
+		Ω(err).Should(Succeed())
+		Ω(op.Timeout).Should(Equal(time.Microsecond))
+		Ω(op.MaxBody).Should(Equal(ByteSize(1024)))
+	})
+
+	It("Bad value", func() {
+		cfg.write(`[foo]
+Timeout = "soon"
+`)
+		_, err := decode()
+		Ω(err).Should(MatchError(`[config] decode 'foo.Timeout': time: invalid duration "soon" (` + cfg.file + ":2)"))
+
+		cfg.write(`[foo]
+Color = "green"
+`)
+		_, err = decode()
+		Ω(err).Should(MatchError("[config] decode 'foo.Color': unknown color (" + cfg.file + ":2)"))
+	})
+
+	It("Override field", func() {
+		s := NewOverrideScope().
+			Field("foo", "Timeout", "5s").
+			Field("foo", "Endpoint", "http://x").
+			Field("foo", "Primary.Timeout", 2*time.Second)
+		defer func() {
+			Ω(s.Close()).Should(Succeed())
+		}()
+
+		Ω(Load("")).Should(Succeed())
+		dump, err := DumpEffectiveOptions()
+		Ω(err).Should(Succeed())
+		Ω(dump).Should(ContainSubstring(`Timeout = "5s" # override`))
+		Ω(dump).Should(ContainSubstring(`Endpoint = "http://x" # override`))
+		Ω(dump).Should(ContainSubstring(`[foo.Primary]
+Timeout = "2s" # override`))
+	})
+
+	It("Dump round trip", func() {
+		dump, err := DumpDefaultOptions()
+		Ω(err).Should(Succeed())
+		Ω(dump).Should(ContainSubstring(`# Timeout = "30s"`))
+		Ω(dump).Should(ContainSubstring(`# MaxBody = "10MiB"`))
+		Ω(dump).Should(ContainSubstring(`# Pattern = "^[a-z]+$"`))
+
+		// skip the header comment
+		lines := strings.Split(dump, "\n")[1:]
+		for i, l := range lines {
+			lines[i] = strings.TrimPrefix(l, "# ")
+		}
+		cfg.write(strings.Join(lines, "\n"))
+		op, err := decode()
+		Ω(err).Should(Succeed())
+		Ω(ChangedFields(op, newHookOption())).Should(BeEmpty())
+	})
+
+	It("Undone by reset", func() {
+		RegisterTypeHook(reflect.TypeOf(time.Duration(0)), TypeHook{
+			Decode: func(v interface{}) (interface{}, error) {
+				return time.Duration(0), nil
+			},
+			Encode: func(v interface{}) (interface{}, error) {
+				return "custom", nil
+			},
+		})
+		ResetInternal()
+		reset.Disable()
+		reset.Enable()
+
+		Register("foo", newHookOption)
+		dump, err := DumpDefaultOptions()
+		Ω(err).Should(Succeed())
+		Ω(dump).Should(ContainSubstring(`# Timeout = "30s"`), "built-in hook restored")
+		Ω(dump).Should(ContainSubstring(`# Color = 0`), "color hook removed")
+	})
+
+	It("Changed regexp", func() {
+		op1, op2 := newHookOption().(*hookOption), newHookOption().(*hookOption)
+		op2.Pattern = regexp.MustCompile("^b$")
+		Ω(ChangedFields(op1, op2)).Should(Equal([]string{"Pattern"}))
+	})
+
+	DescribeTable("ByteSize", func(s string, exp ByteSize, str string) {
+		v, err := ParseByteSize(s)
+		Ω(err).Should(Succeed())
+		Ω(v).Should(Equal(exp))
+		Ω(v.String()).Should(Equal(str))
+	},
+		Entry("bytes", "100", ByteSize(100), "100B"),
+		Entry("B", "100B", ByteSize(100), "100B"),
+		Entry("KB", "2KB", ByteSize(2000), "2KB"),
+		Entry("KiB", "2kib", ByteSize(2048), "2KiB"),
+		Entry("fraction", "1.5 GiB", ByteSize(3<<29), "1536MiB"),
+		Entry("zero", "0", ByteSize(0), "0B"),
+	)
+
+	It("Bad ByteSize", func() {
+		_, err := ParseByteSize("10XB")
+		Ω(err).Should(MatchError("invalid byte size '10XB'"))
+		_, err = ParseByteSize("")
+		Ω(err).Should(HaveOccurred())
+	})
+
+})
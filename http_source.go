@@ -0,0 +1,260 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/redforks/testing/reset"
+)
+
+// DefaultPollInterval is the default interval HTTPSource polls config server.
+const DefaultPollInterval = time.Minute
+
+// HTTPSource fetches config file from a config server by HTTP(S), and saves
+// it to CacheFile. The cached file loaded as a normal config file, and used on
+// startup if config server not available.
+//
+// Config server should support ETag, HTTPSource polls with If-None-Match
+// header, no reload if server returns 304 Not Modified.
+//
+// If a Verifier set by SetVerifier(), checksum and signature files are fetched
+// from URL + ".sha256" and URL + ".sig", and cached beside CacheFile, missing
+// ones are skipped.
+type HTTPSource struct {
+	URL       string
+	CacheFile string
+
+	// Interval of polling, default to DefaultPollInterval.
+	Interval time.Duration
+
+	// Client used to fetch config, default to http.DefaultClient.
+	Client *http.Client
+
+	etag string
+}
+
+// NewHTTPSource creates HTTPSource fetches url, cached to cacheFile.
+func NewHTTPSource(url, cacheFile string) *HTTPSource {
+	return &HTTPSource{
+		URL:       url,
+		CacheFile: cacheFile,
+		Interval:  DefaultPollInterval,
+	}
+}
+
+// SetTLSClientCert authenticate to config server by client certificate. If
+// caFile not empty, verify server certificate by it instead of system CAs.
+func (s *HTTPSource) SetTLSClientCert(certFile, keyFile, caFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("[%s] no certificate found in '%s'", tag, caFile)
+		}
+	}
+
+	s.Client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	return nil
+}
+
+func (s *HTTPSource) etagFile() string {
+	return s.CacheFile + ".etag"
+}
+
+// Fetch config from server, returns true if CacheFile updated. Fetched config
+// decoded and validated the same as DecodeFile(), CacheFile and its ETag not
+// updated if failed.
+func (s *HTTPSource) Fetch() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if s.etag == "" {
+		// etag of cached file from last run
+		if buf, err := ioutil.ReadFile(s.etagFile()); err == nil {
+			s.etag = string(buf)
+		}
+	}
+	if s.etag != "" {
+		if _, err = os.Stat(s.CacheFile); err == nil {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("[%s] fetch '%s': %s", tag, s.URL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	sidecars, err := s.fetchSidecars(client)
+	if err != nil {
+		return false, err
+	}
+	// keep the last good copy if server returns a bad config
+	if err = writeFileChecked(s.CacheFile, body, 0600, func(tmp string) error {
+		// verifier reads sidecar files beside the file verified
+		for suffix, data := range sidecars {
+			if err := ioutil.WriteFile(tmp+suffix, data, 0600); err != nil {
+				return err
+			}
+			defer os.Remove(tmp + suffix)
+		}
+		if _, err := DecodeFile(tmp); err != nil {
+			return fmt.Errorf("[%s] bad config from '%s': %s", tag, s.URL, err)
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	if err = s.saveSidecars(sidecars); err != nil {
+		return true, err
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	if s.etag != "" {
+		err = writeFileAtomic(s.etagFile(), []byte(s.etag), 0600)
+	} else {
+		err = os.Remove(s.etagFile())
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	logInfo("fetched config from server", Field{"url", s.URL}, Field{"file", s.CacheFile})
+	return true, err
+}
+
+// verifySuffixes are suffixes of sidecar files read by builtin verifiers.
+var verifySuffixes = []string{".sha256", ".sig"}
+
+// fetchSidecars fetches sidecar files of verifier from URL + suffix, keyed by
+// suffix, not found ones are skipped. Returns nil if no verifier set.
+func (s *HTTPSource) fetchSidecars(client *http.Client) (map[string][]byte, error) {
+	if verifier == nil {
+		return nil, nil
+	}
+
+	r := make(map[string][]byte)
+	for _, suffix := range verifySuffixes {
+		resp, err := client.Get(s.URL + suffix)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			continue
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("[%s] fetch '%s': %s", tag, s.URL+suffix, resp.Status)
+		case err != nil:
+			return nil, err
+		}
+		r[suffix] = data
+	}
+	return r, nil
+}
+
+// saveSidecars saves fetched sidecar files beside CacheFile, removes stale
+// ones not fetched. Nothing changed if sidecars is nil, no verifier set.
+func (s *HTTPSource) saveSidecars(sidecars map[string][]byte) error {
+	if sidecars == nil {
+		return nil
+	}
+
+	for _, suffix := range verifySuffixes {
+		var err error
+		if data, ok := sidecars[suffix]; ok {
+			err = writeFileAtomic(s.CacheFile+suffix, data, 0600)
+		} else if err = os.Remove(s.CacheFile + suffix); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Poll fetches config from server every Interval until stop closed, calls
+// Reload() if config changed. stop can be nil to poll forever.
+func (s *HTTPSource) Poll(stop <-chan struct{}) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := s.Fetch()
+		if err != nil {
+			logWarn("fetch config from server failed", Field{"url", s.URL}, Field{"err", err})
+			continue
+		}
+		if changed {
+			Reload()
+		}
+	}
+}
+
+var httpSource *HTTPSource
+
+// UseHTTPSource load config from config server instead of local config file,
+// must be called before Load() or life.Start(). Load() fetches config, and
+// falls back to cached file if fetch failed. On life.Start() also starts
+// polling.
+func UseHTTPSource(s *HTTPSource) {
+	httpSource = s
+}
+
+// fetchHTTPSource fetch config on Load(), returns the cache file to load.
+func fetchHTTPSource() string {
+	if _, err := httpSource.Fetch(); err != nil {
+		logWarn("fetch config from server failed, use cached file", Field{"url", httpSource.URL}, Field{"file", httpSource.CacheFile}, Field{"err", err})
+	}
+	return httpSource.CacheFile
+}
+
+func init() {
+	reset.Register(func() {
+		httpSource = nil
+	}, nil)
+}
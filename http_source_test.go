@@ -0,0 +1,254 @@
+package config_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPSource", func() {
+
+	var (
+		cfg       = setupTestConfig()
+		cacheFile string
+		server    *httptest.Server
+		content   string
+		etag      string
+		requests  []*http.Request
+		src       *HTTPSource
+	)
+
+	BeforeEach(func() {
+		cacheFile = cfg.path("app.conf")
+		requests = nil
+		content, etag = `[server]
+Addr = ":8080"
+`, `"v1"`
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write([]byte(content))
+		}))
+		src = NewHTTPSource(server.URL, cacheFile)
+
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Fetch", func() {
+		Ω(src.Fetch()).Should(BeTrue())
+		Ω(ioutil.ReadFile(cacheFile)).Should(BeEquivalentTo(content))
+
+		Ω(src.Fetch()).Should(BeFalse())
+		Ω(requests[1].Header.Get("If-None-Match")).Should(Equal(`"v1"`))
+
+		content, etag = "", `"v2"`
+		Ω(src.Fetch()).Should(BeTrue())
+		Ω(ioutil.ReadFile(cacheFile)).Should(BeEquivalentTo(""))
+	})
+
+	It("ETag survives restart", func() {
+		Ω(src.Fetch()).Should(BeTrue())
+		Ω(NewHTTPSource(server.URL, cacheFile).Fetch()).Should(BeFalse())
+	})
+
+	It("Server error", func() {
+		server.Config.Handler = http.NotFoundHandler()
+		_, err := src.Fetch()
+		Ω(err).Should(MatchError(ContainSubstring("404 Not Found")))
+	})
+
+	It("Keep cached file if fetched config is bad", func() {
+		Ω(src.Fetch()).Should(BeTrue())
+
+		content, etag = `[server]
+Addr = 1
+`, `"v2"`
+		_, err := src.Fetch()
+		Ω(err).Should(MatchError(HavePrefix("[config] bad config from '" + server.URL + "': ")))
+		Ω(ioutil.ReadFile(cacheFile)).Should(BeEquivalentTo(`[server]
+Addr = ":8080"
+`))
+		Ω(ioutil.ReadFile(cacheFile + ".etag")).Should(BeEquivalentTo(`"v1"`))
+		Ω(ioutil.ReadDir(cfg.dir.Dir())).Should(HaveLen(2), "temp file removed")
+	})
+
+	It("Load", func() {
+		UseHTTPSource(src)
+		Ω(Load("")).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":8080"))
+	})
+
+	It("Load cached file if server not available", func() {
+		Ω(src.Fetch()).Should(BeTrue())
+		server.Close()
+
+		UseHTTPSource(NewHTTPSource(server.URL, cacheFile))
+		Ω(Load("")).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":8080"))
+	})
+
+	It("Poll", func() {
+		events := make(chan LoadEvent, 10)
+		AddLoadHook(func(ev LoadEvent) {
+			events <- ev
+		})
+		UseHTTPSource(src)
+		Ω(Load("")).Should(Succeed())
+		Ω(events).Should(Receive())
+
+		content, etag = `[server]
+Addr = ":8081"
+`, `"v2"`
+		src.Interval = 10 * time.Millisecond
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			src.Poll(stop)
+		}()
+
+		var ev LoadEvent
+		Eventually(events).Should(Receive(&ev))
+		close(stop)
+		<-done
+		Ω(ev.Reload).Should(BeTrue())
+		Ω(ev.Err).Should(BeNil())
+		Ω(addrOp.Addr).Should(Equal(":8081"))
+	})
+
+	Context("Verifier", func() {
+
+		var checksum string
+
+		BeforeEach(func() {
+			checksum = ""
+			handler := server.Config.Handler
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/app.conf.sha256":
+					_, _ = w.Write([]byte(checksum))
+				case "/app.conf.sig":
+					http.NotFound(w, r)
+				default:
+					handler.ServeHTTP(w, r)
+				}
+			})
+			src = NewHTTPSource(server.URL+"/app.conf", cacheFile)
+			SetVerifier(ChecksumVerifier())
+		})
+
+		It("Fetch checksum file", func() {
+			sum := sha256.Sum256([]byte(content))
+			checksum = hex.EncodeToString(sum[:]) + "  app.conf\n"
+			Ω(src.Fetch()).Should(BeTrue())
+			Ω(ioutil.ReadFile(cacheFile + ".sha256")).Should(BeEquivalentTo(checksum))
+
+			UseHTTPSource(src)
+			Ω(Load("")).Should(Succeed())
+			Ω(addrOp.Addr).Should(Equal(":8080"))
+		})
+
+		It("Checksum mismatch", func() {
+			checksum = strings.Repeat("0", 64)
+			_, err := src.Fetch()
+			Ω(err).Should(MatchError(ContainSubstring("checksum mismatch")))
+			Ω(ioutil.ReadDir(cfg.dir.Dir())).Should(BeEmpty(), "temp files removed")
+		})
+
+	})
+
+	Context("TLS client certificate", func() {
+
+		var certFile, keyFile, caFile string
+
+		BeforeEach(func() {
+			certFile, keyFile, caFile = cfg.path("client.crt"), cfg.path("client.key"), cfg.path("ca.crt")
+			clientCert := writeClientCert(certFile, keyFile)
+
+			handler := server.Config.Handler
+			server.Close()
+			server = httptest.NewUnstartedServer(handler)
+			server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+			server.TLS.ClientCAs.AddCert(clientCert)
+			server.StartTLS()
+
+			Ω(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)).Should(Succeed())
+			src = NewHTTPSource(server.URL, cacheFile)
+		})
+
+		It("Fetch", func() {
+			Ω(src.SetTLSClientCert(certFile, keyFile, caFile)).Should(Succeed())
+			Ω(src.Fetch()).Should(BeTrue())
+			Ω(ioutil.ReadFile(cacheFile)).Should(BeEquivalentTo(content))
+		})
+
+		It("Rejected without client certificate", func() {
+			src.Client = server.Client()
+			_, err := src.Fetch()
+			Ω(err).Should(HaveOccurred())
+			Ω(requests).Should(BeEmpty())
+		})
+
+		It("No certificate in CA file", func() {
+			Ω(ioutil.WriteFile(caFile, []byte("foo"), 0600)).Should(Succeed())
+			Ω(src.SetTLSClientCert(certFile, keyFile, caFile)).Should(MatchError("[config] no certificate found in '" + caFile + "'"))
+		})
+
+	})
+
+})
+
+// writeClientCert generates a self signed client certificate and its key,
+// write them to files in PEM format.
+func writeClientCert(certFile, keyFile string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ω(err).Should(Succeed())
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Ω(err).Should(Succeed())
+	cert, err := x509.ParseCertificate(der)
+	Ω(err).Should(Succeed())
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Ω(err).Should(Succeed())
+	Ω(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).Should(Succeed())
+	Ω(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).Should(Succeed())
+	return cert
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redforks/appinfo"
+	"github.com/redforks/hal"
+	"github.com/redforks/xdgdirs"
+)
+
+// builtinVars can be referenced in config values, such as
+// "${xdg.DataHome}/foo". Built-in variables take precedence over config keys.
+var builtinVars = map[string]func() string{
+	"app.CodeName":    appinfo.CodeName,
+	"xdg.Home":        xdgdirs.Home,
+	"xdg.ConfigHome":  xdgdirs.ConfigHome,
+	"xdg.DataHome":    xdgdirs.DataHome,
+	"xdg.CacheHome":   xdgdirs.CacheHome,
+	"xdg.RuntimeHome": xdgdirs.RuntimeHome,
+}
+
+// interpolator expands "${NAME}" in string values of config document. NAME is
+// one of:
+//
+//  1. Built-in variable, see builtinVars.
+//  2. Config key in "option.Key" form, the value in config file, or the
+//     default value of the option if not set in config file.
+//  3. Environment variable, error if not defined.
+//
+// "$${" is escaped to literal "${".
+type interpolator struct {
+	doc Document
+
+	// config keys in resolving, to detect reference cycles
+	resolving map[string]bool
+	resolved  map[string]string
+}
+
+// interpolateDocument expands variables in all option tables of doc.
+func interpolateDocument(doc Document) error {
+	in := &interpolator{doc, make(map[string]bool), make(map[string]string)}
+	return walkSections(doc, func(path, s string) (string, error) {
+		// resolve config keys once, other keys may reference them, expanding
+		// the written back value again unescapes "$${" twice.
+		if v, ok := lookupPath(doc, strings.Split(path, ".")); ok {
+			if _, ok = v.(string); ok {
+				return in.configValue(path)
+			}
+		}
+		return in.expand(s)
+	})
+}
+
+func (in *interpolator) expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	buf := strings.Builder{}
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			buf.WriteString(s)
+			return buf.String(), nil
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			buf.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("[%s] unclosed '${' in '%s'", tag, s)
+		}
+		buf.WriteString(s[:i])
+
+		v, err := in.lookup(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(v)
+		s = s[i+end+1:]
+	}
+}
+
+func (in *interpolator) lookup(name string) (string, error) {
+	if fn, ok := builtinVars[name]; ok {
+		return fn(), nil
+	}
+
+	if strings.Contains(name, ".") {
+		return in.configValue(name)
+	}
+
+	if v := hal.Getenv(name); v != "" {
+		return v, nil
+	}
+	return "", fmt.Errorf("[%s] undefined variable '${%s}'", tag, name)
+}
+
+func (in *interpolator) configValue(key string) (string, error) {
+	if v, ok := in.resolved[key]; ok {
+		return v, nil
+	}
+	if in.resolving[key] {
+		return "", fmt.Errorf("[%s] reference cycle of '${%s}'", tag, key)
+	}
+	in.resolving[key] = true
+	defer delete(in.resolving, key)
+
+	v, err := lookupKey(in.doc, key)
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	} else if s, err = in.expand(s); err != nil {
+		return "", err
+	}
+	in.resolved[key] = s
+	return s, nil
+}
+
+// lookupKey returns value of "option.Key" in doc, or default value of the
+// option if not in doc.
+func lookupKey(doc Document, key string) (interface{}, error) {
+	path := strings.Split(key, ".")
+	if v, ok := lookupPath(doc, path); ok {
+		return v, nil
+	}
+
+	for _, rec := range options {
+		if !strings.HasPrefix(key, rec.name+".") {
+			continue
+		}
+
+		def, err := optionDocument(rec.creator())
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := lookupPath(def, splitName(key[len(rec.name)+1:])); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("[%s] undefined variable '${%s}'", tag, key)
+}
+
+func lookupPath(m map[string]interface{}, path []string) (interface{}, bool) {
+	for i, p := range path {
+		v, ok := m[p]
+		if !ok {
+			return nil, false
+		}
+		if i == len(path)-1 {
+			return v, true
+		}
+		if m, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
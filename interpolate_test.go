@@ -0,0 +1,95 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/hal"
+)
+
+var _ = Describe("Interpolate", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		hal.Getenv = func(name string) string {
+			switch name {
+			case "HOST":
+				return "example.com"
+			case "HOME":
+				return "/home/foo"
+			}
+			return ""
+		}
+
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80", Timeout: 3}
+		})
+		Register("foo", func() Option {
+			return &SimpleOption{"bar"}
+		})
+	})
+
+	load := func(content string) {
+		cfg.write(content)
+		Ω(Load(cfg.file)).Should(Succeed())
+	}
+
+	It("Environment variable", func() {
+		load(`[server]
+Addr = "${HOST}:80"
+`)
+		Ω(addrOp.Addr).Should(Equal("example.com:80"))
+	})
+
+	It("Built-in variables", func() {
+		load(`[server]
+Addr = "${app.CodeName}@${xdg.ConfigHome}"
+`)
+		Ω(addrOp.Addr).Should(Equal("test@/home/foo/.config"))
+	})
+
+	It("Config keys", func() {
+		load(`[server]
+Addr = "${foo.Name}:${server.Timeout}"
+
+[foo]
+Name = "${HOST}"
+`)
+		Ω(addrOp.Addr).Should(Equal("example.com:3"))
+	})
+
+	It("Escape", func() {
+		load(`[server]
+Addr = "$${HOST}"
+
+[foo]
+Name = "${server.Addr}"
+`)
+		Ω(addrOp.Addr).Should(Equal("${HOST}"))
+
+		// referenced escaped value unescaped only once
+		opts, err := DecodeFile(cfg.file)
+		Ω(err).Should(Succeed())
+		Ω(opts["foo"].(*SimpleOption).Name).Should(Equal("${HOST}"))
+	})
+
+	It("Undefined", func() {
+		cfg.write(`[server]
+Addr = "${NOT_EXIST}"
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] undefined variable '${NOT_EXIST}'"))
+	})
+
+	It("Cycle", func() {
+		cfg.write(`[server]
+Addr = "${foo.Name}"
+
+[foo]
+Name = "${server.Addr}"
+`)
+		Ω(Load(cfg.file)).Should(MatchError(ContainSubstring("reference cycle")))
+	})
+
+})
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"log"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RegisterLate registers an option after life.Initing phase, such as by a
+// plugin loaded at runtime. Panics on name conflict, same as Register().
+//
+// If options already inited, the option is decoded from the config file of
+// applied options, as it was loaded, not re-read from disk, and inited
+// immediately. If that failed the option is not registered and the error
+// returned. The option is included in following reloads and dumps.
+//
+// RegisterLate() and Unregister() are safe to call while other goroutines
+// read options, such as by FieldProvenance() or DumpEffectiveOptions().
+func RegisterLate(name string, fnCreateDefault OptionCreator) error {
+	checkOptionName(name)
+
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	checkRegister(name, fnCreateDefault)
+	rec := &optionRec{name: name, creator: fnCreateDefault}
+	addOption(rec)
+	if !inited {
+		return nil
+	}
+
+	if err := initLateOption(rec); err != nil {
+		removeOption(rec.name)
+		return err
+	}
+	updateVersions()
+	return nil
+}
+
+// initLateOption decodes option rec from the config file of applied options,
+// not re-reading the file, then validates and inits it.
+func initLateOption(rec *optionRec) error {
+	op, src, err := defaultOption(rec)
+	if err != nil {
+		return err
+	}
+
+	if file := appliedFile; file != nil {
+		var doc Document
+		if _, err = toml.Decode(string(file.data), &doc); err != nil {
+			return err
+		}
+		if err = prepareDocument(doc, file); err != nil {
+			return err
+		}
+		if err = file.recordOption(doc, rec, src); err != nil {
+			return locateError(err, file)
+		}
+		if _, err = decodeOption(doc, rec, op); err != nil {
+			return locateError(err, file)
+		}
+	}
+	if err = validateOption(rec, op, appliedFile); err != nil {
+		return err
+	}
+
+	logDebug("initing option", Field{"option", rec.name})
+	if err = op.Init(); err != nil {
+		return err
+	}
+	optionsLock.Lock()
+	rec.op, rec.initOp, rec.sources = op, op, src
+	optionsLock.Unlock()
+	return nil
+}
+
+// Unregister removes a registered option, such as a plugin unloaded. The
+// option is no longer reloaded or dumped, Unregister() does not notify the
+// option. Panics if not registered.
+func Unregister(name string) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	if !removeOption(name) {
+		log.Panicf("[%s] unregister unknown option '%s'", tag, name)
+	}
+	logInfo("option unregistered", Field{"option", name})
+	if inited {
+		updateVersions()
+	}
+}
+
+// removeOption removes option name from options, returns false if not
+// registered.
+func removeOption(name string) bool {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+
+	for i, rec := range options {
+		if rec.name == name {
+			options = append(options[:i], options[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
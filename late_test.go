@@ -0,0 +1,119 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/errors"
+	"github.com/redforks/testing/matcher"
+)
+
+var _ = Describe("Late registration", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		Register("foo", newFakeOption(0))
+		cfg.write(`[plugin]
+Name = "plugin"
+`)
+	})
+
+	It("Before load", func() {
+		Ω(RegisterLate("plugin", newFakeOption(1))).Should(Succeed())
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(ops[1].Name).Should(Equal("plugin"))
+	})
+
+	It("After load", func() {
+		Ω(Load(cfg.file)).Should(Succeed())
+		v := CurrentVersion()
+
+		Ω(RegisterLate("plugin", newFakeOption(1))).Should(Succeed())
+		Ω(initHits).Should(Equal([]int{1, 1}))
+		Ω(ops[1].Name).Should(Equal("plugin"))
+		Ω(CurrentVersion().Generation).Should(Equal(v.Generation + 1))
+		Ω(DumpEffectiveOptions()).Should(ContainSubstring("[plugin]"))
+
+		cfg.write(`[plugin]
+Name = "plugin1"
+`)
+		Reload()
+		Ω(applyHits).Should(Equal([]int{0, 1}))
+		Ω(ops[1].Name).Should(Equal("plugin1"))
+	})
+
+	It("Decode from applied config, not file on disk", func() {
+		Ω(Load(cfg.file)).Should(Succeed())
+		cfg.write(`[plugin`)
+
+		Ω(RegisterLate("plugin", newFakeOption(1))).Should(Succeed())
+		Ω(ops[1].Name).Should(Equal("plugin"))
+		p, _ := FieldProvenance("plugin", "Name")
+		Ω(p).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 2}))
+	})
+
+	It("Init failed", func() {
+		Ω(Load(cfg.file)).Should(Succeed())
+		initErrors = []error{nil, errors.New("error")}
+		Ω(RegisterLate("plugin", newFakeOption(1))).Should(Equal(initErrors[1]))
+		_, exist := OptionVersion("plugin")
+		Ω(exist).Should(BeFalse())
+	})
+
+	It("Name conflict", func() {
+		Ω(func() {
+			_ = RegisterLate("foo", newFakeOption(1))
+		}).Should(matcher.Panics("[config] option 'foo' already registered"))
+	})
+
+	It("Unregister", func() {
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(RegisterLate("plugin", newFakeOption(1))).Should(Succeed())
+		Unregister("plugin")
+
+		cfg.write(`[plugin]
+Name = "plugin1"
+`)
+		Reload()
+		Ω(applyHits).Should(Equal([]int{0, 0}))
+		Ω(DumpEffectiveOptions()).ShouldNot(ContainSubstring("[plugin]"))
+	})
+
+	It("Read options while registering", func() {
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		// run with -race to detect unguarded access
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				_, _ = OptionVersion("plugin")
+				_, _ = FieldProvenance("plugin", "Name")
+				_, _ = OptionProvenance("plugin")
+				_ = RestartPending()
+				_, err := DumpEffectiveOptions()
+				Ω(err).Should(Succeed())
+				_, err = DecodeFile(cfg.file)
+				Ω(err).Should(Succeed())
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			Ω(RegisterLate("plugin", func() Option {
+				return &PortOption{80}
+			})).Should(Succeed())
+			Reload()
+			Unregister("plugin")
+		}
+		<-done
+	})
+
+	It("Unregister unknown", func() {
+		Ω(func() {
+			Unregister("plugin")
+		}).Should(matcher.Panics("[config] unregister unknown option 'plugin'"))
+	})
+
+})
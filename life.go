@@ -16,7 +16,7 @@ import (
 func start() {
 	if reset.TestMode() {
 		// In test mode, apply default options
-		if err := initAllOptions(nil); err != nil {
+		if err := initAllOptions(nil, nil); err != nil {
 			panic(err)
 		}
 		return
@@ -31,11 +31,50 @@ func start() {
 		hal.Exit(0)
 	}
 
+	if showConfigSearch {
+		if filename != "" {
+			fmt.Printf("%s: specified by command line\n", filename)
+		} else {
+			fmt.Print(Discover())
+		}
+		hal.Exit(0)
+	}
+
+	if dumpJSONSchema {
+		s, err := JSONSchema()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(s))
+		hal.Exit(0)
+	}
+
+	if migrateConfig {
+		if err := MigrateFile(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			hal.Exit(1)
+		}
+		hal.Exit(0)
+	}
+
+	if checkConfig {
+		if err := ValidateFile(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			hal.Exit(1)
+		}
+		fmt.Println("config file OK")
+		hal.Exit(0)
+	}
+
 	// only start signal monitor on non-test mode
 	go monitorSignal()
 	if err := Load(""); err != nil {
 		log.Panic(err)
 	}
+	// Load() fetched already, poll after it, so they not fetch concurrently
+	if httpSource != nil {
+		go httpSource.Poll(nil)
+	}
 }
 
 // monitor USR1 signal to reload config and apply
@@ -1,15 +1,13 @@
 package config
 
 import (
-	"log"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 
-	"github.com/redforks/appinfo"
 	"github.com/redforks/life"
-	"github.com/redforks/xdgdirs"
 
-	"github.com/BurntSushi/toml"
 	"github.com/redforks/errors"
 	"github.com/redforks/hal"
 )
@@ -17,40 +15,53 @@ import (
 // Load config file manually. Normally loading config file is triggered by life
 // package before life starting. For non-daemon applications, do not start life
 // cycle, calling Load() manually in this case.
-// Load can only be called once, it will fail if calling config.Load() and
-// life.Start().
+// Load fails if options already loaded, such as calling both config.Load()
+// and life.Start(), call Unload() first to load again.
 //
-// Note: Use alternative config filename in server mode is not supported.
+// Use SwitchFile() to change config file after Load().
 //
-// If configFile is empty, use default config file resolve process, and this is
-// recommended.
+// If configFile is empty, find config file in search path, see SetSearchPath()
+// and SetFileNames(), this is recommended.
 func Load(configFile string) (err error) {
 	if loaded {
 		return errors.Bugf("[%s] can not call Load() twice", tag)
 	}
 
 	loaded = true
+	loadStart := hal.Now()
+	defer func() {
+		notifyLoad(LoadEvent{File: filename, Duration: hal.Now().Sub(loadStart), Err: err})
+	}()
 
 	if configFile != "" {
 		filename = configFile
+	} else if httpSource != nil {
+		filename = fetchHTTPSource()
 	}
 
 	if filename == "" {
 		var err error
-		filename, err = xdgdirs.ResolveConfigFile(appinfo.CodeName() + ".conf")
+		filename, err = resolveConfigFile("")
 
 		if err != nil {
-			return initAllOptions(nil)
+			return initAllOptions(nil, nil)
 		}
 	}
 
-	log.Printf("[%s] loading %s", tag, filename)
-	var opts []Option
-	if opts, err = loadConfigFile(); err != nil {
+	logInfo("loading config file", Field{"file", filename})
+	var (
+		opts []Option
+		srcs []*optionSources
+		file *fileSource
+	)
+	if opts, srcs, file, err = loadConfigFile(filename); err != nil {
 		return
 	}
 
-	return initAllOptions(opts)
+	if err = initAllOptions(opts, srcs); err == nil {
+		appliedFile = file
+	}
+	return
 }
 
 var reloadLock sync.Mutex
@@ -60,82 +71,245 @@ func Reload() {
 	reloadLock.Lock()
 	defer reloadLock.Unlock()
 
-	defer func() {
-		e := recover()
-		if e != nil {
-			errors.Handle(nil, e)
-			hal.Exit(20)
-		}
-	}()
+	defer recoverApply()
 
 	if life.State() == life.Shutingdown {
-		log.Printf("[%s] abort reload on %s phase", tag, life.State())
+		logWarn("abort reload", Field{"phase", life.State()})
 		return
 	}
-	log.Printf("[%s] SIGUSR1 detected, reloading configfile '%s'", tag, filename)
+	if !loaded || !inited {
+		// not loaded yet, or unloaded by Unload()
+		logInfo("ignore reload, options not loaded")
+		return
+	}
+	logInfo("reloading config file", Field{"file", filename})
 
 	if filename == "" {
 		// ignore reload() request if no config file attached
 		return
 	}
 
+	_ = reloadFile(filename)
+}
+
+// SwitchFile loads config file at path and make it the active config file,
+// changed options are applied the same as Reload(). Following Reload() reads
+// the new file. If failed, current config file and options are kept.
+//
+// Load() must be called before SwitchFile().
+func SwitchFile(path string) error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	defer recoverApply()
+
+	if !loaded {
+		return errors.Bugf("[%s] can not switch config file before Load()", tag)
+	}
+	if life.State() == life.Shutingdown {
+		return errors.Runtimef("[%s] can not switch config file on %s phase", tag, life.State())
+	}
+
+	// unlike Reload(), the new file must exist
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	logInfo("switching config file", Field{"from", filename}, Field{"file", path})
+	if err := reloadFile(path); err != nil {
+		return err
+	}
+	filename = path
+	return nil
+}
+
+// recoverApply exit application if Apply() panics on reload, options are in
+// unknown state.
+func recoverApply() {
+	e := recover()
+	if e != nil {
+		errors.Handle(nil, e)
+		hal.Exit(20)
+	}
+}
+
+// reloadFile loads config file at path and apply changed options, keep
+// current options if load failed.
+func reloadFile(path string) error {
 	var (
-		opts    []Option
-		err     error
-		changed bool
+		changed     bool
+		reloadStart = hal.Now()
 	)
-	if opts, err = loadConfigFile(); err != nil {
-		log.Printf("[%s] error when reloading configfile: %s", tag, err.Error())
-		return
+	opts, srcs, file, err := loadConfigFile(path)
+	if err != nil {
+		logError("reload failed", Field{"file", path}, Field{"err", err})
+		notifyLoad(LoadEvent{Reload: true, File: path, Duration: hal.Now().Sub(reloadStart), Err: err})
+		return err
 	}
 
+	var restarts []string
 	for i, rec := range options {
-		if optionChanged(opts[i], rec.op) {
-			log.Printf("[%s] '%s' option chanegd, applying", tag, rec.name)
+		if pending := restartFields(rec.name, rec.initOp, opts[i]); len(pending) != 0 {
+			restarts = append(restarts, pending...)
+			if restartPolicy == RefuseRestartRequired {
+				logWarn("option not applied, restart required", Field{"option", rec.name}, Field{"keys", pending})
+				opts[i], srcs[i] = rec.op, rec.sources
+				continue
+			}
+			logWarn("restart required to apply", Field{"option", rec.name}, Field{"keys", pending})
+		}
+
+		if rec.item != nil {
+			applyStart := hal.Now()
+			if applyMap(rec, rec.op.(OptionMap), opts[i].(OptionMap)) {
+				notifyApply(ApplyEvent{rec.name, hal.Now().Sub(applyStart)})
+				changed = true
+			}
+			continue
+		}
+
+		if keys := ChangedFields(rec.op, opts[i]); len(keys) != 0 {
+			applyStart := hal.Now()
 			opts[i].Apply()
+			d := hal.Now().Sub(applyStart)
+			logInfo("option applied", Field{"option", rec.name}, Field{"keys", keys}, Field{"duration", d})
+			notifyApply(ApplyEvent{rec.name, d})
 			changed = true
 		}
 	}
 	if changed {
-		log.Printf("[%s] applied all changed options", tag)
+		logInfo("applied all changed options", Field{"file", path}, Field{"duration", hal.Now().Sub(reloadStart)})
 	} else {
-		log.Printf("[%s] no options changed", tag)
+		logInfo("no options changed", Field{"file", path})
 	}
 
-	storeOptions(opts)
+	optionsLock.Lock()
+	restartPending = restarts
+	optionsLock.Unlock()
+	storeOptions(opts, srcs)
+	appliedFile = file
+	notifyLoad(LoadEvent{Reload: true, File: path, Duration: hal.Now().Sub(reloadStart)})
+	return nil
 }
 
-func loadConfigFile() (opts []Option, err error) {
-	var (
-		dict map[string]toml.Primitive
-		md   toml.MetaData
-	)
-
-	if opts, err = getDefaultOptions(); err != nil {
+// loadConfigFile returns options decoded from config file at path, and
+// provenance of their fields, in options order. Returns nil file if config
+// file not exist.
+func loadConfigFile(path string) (opts []Option, srcs []*optionSources, file *fileSource, err error) {
+	var keys []string
+	if opts, srcs, err = getDefaultOptions(); err != nil {
 		return
 	}
 
-	if md, err = toml.DecodeFile(filename, &dict); err != nil {
+	if keys, file, err = decodeConfigFile(path, opts, srcs); err != nil {
 		if !os.IsNotExist(err) {
 			return
 		}
 
 		// file not exist, log and continue
-		log.Printf("[%s] %s", tag, err.Error())
+		logInfo("config file not exist, use default options", Field{"file", path})
 		err = nil
 	}
 
-	for i, rec := range options {
-		if p, ok := dict[rec.name]; ok {
-			if err = md.PrimitiveDecode(p, opts[i]); err != nil {
-				return
-			}
+	if len(keys) != 0 {
+		logWarn("unknown keys in config file are ignored, possibly wrong spelling", Field{"file", path}, Field{"keys", keys})
+	}
+
+	err = validateOptions(opts, file)
+	return
+}
+
+// decodeConfigFile decode config file at path into opts, and record
+// provenance of fields set by the file to srcs if not nil, opts and srcs are
+// in options order. Returns keys in config file not decoded to any option, and
+// the file to locate keys in it.
+func decodeConfigFile(path string, opts []Option, srcs []*optionSources) ([]string, *fileSource, error) {
+	doc, file, err := readDocument(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = prepareDocument(doc, file); err != nil {
+		return nil, nil, err
+	}
+	if srcs != nil {
+		if err = file.record(doc, srcs); err != nil {
+			return nil, nil, locateError(err, file)
 		}
 	}
 
-	keys := md.Undecoded()
-	if len(keys) != 0 {
-		log.Printf("[%s] Warning fowlling keys in config file '%s' are unknow and ignored, possibly wrong spelling. %v", tag, filename, keys)
+	keys, err := decodeDocument(doc, opts)
+	return keys, file, locateError(err, file)
+}
+
+// prepareDocument applies active profile, aliases, migrations, interpolation
+// and decryption to doc read from file, ready to decode.
+func prepareDocument(doc Document, file *fileSource) error {
+	file.profile = ActiveProfile()
+	if err := applyProfile(doc, file.profiled); err != nil {
+		return locateError(err, file)
 	}
-	return
+	if err := resolveAliases(doc); err != nil {
+		return locateError(err, file)
+	}
+	if err := migrateDocument(doc); err != nil {
+		return locateError(err, file)
+	}
+	if err := interpolateDocument(doc); err != nil {
+		return locateError(err, file)
+	}
+	if err := decryptDocument(doc); err != nil {
+		return locateError(err, file)
+	}
+	return nil
+}
+
+// resolveConfigFile returns path if not empty, otherwise find config file in
+// search path.
+func resolveConfigFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	r := Discover()
+	for _, p := range r.Probes {
+		logDebug("find config file", Field{"file", p.Path}, Field{"found", p.Found}, Field{"err", p.Err})
+	}
+	if r.Found == "" {
+		return "", errors.Runtimef("[%s] config file not found", tag)
+	}
+	return r.Found, nil
+}
+
+// writeFileAtomic write data to a temp file in the same directory, then
+// replace file at path by it.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	return writeFileChecked(path, data, mode, nil)
+}
+
+// writeFileChecked is writeFileAtomic, but calls check with the temp file
+// before replacing, file at path not touched if check failed. check can be
+// nil.
+func writeFileChecked(path string, data []byte, mode os.FileMode, check func(tmp string) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && check != nil {
+		err = check(tmp)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
 }
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+
+	"github.com/redforks/testing/reset"
+)
+
+// Level is the severity of a config log event.
+type Level int
+
+const (
+	// LevelDebug for verbose events, such as each option inited.
+	LevelDebug Level = iota
+	// LevelInfo for normal events, such as config file loaded.
+	LevelInfo
+	// LevelWarn for events need attention, such as unknown keys in config file.
+	LevelWarn
+	// LevelError for failures, such as reload failed.
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel parses level name such as "info", case insensitive, "warning"
+// is the same as "warn".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level '%s'", s)
+}
+
+// Field is a structured key/value pair attached to a log event, such as
+// option name, config file, changed keys and duration.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives all log events of config package. Set by SetLogger() to
+// route config events to application log pipeline.
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+type stdLogger struct{}
+
+// Log to standard log package, in "[config] LEVEL msg key=value" format.
+func (stdLogger) Log(level Level, msg string, fields ...Field) {
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "[%s] %s %s", tag, level, msg)
+	for _, f := range fields {
+		fmt.Fprintf(&buf, " %s=%v", f.Key, f.Value)
+	}
+	log.Print(buf.String())
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writes to l, all events have "pkg" attribute
+// set to "config".
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l.With("pkg", tag)}
+}
+
+func (s slogLogger) Log(level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	s.l.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(l Level) slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+var logger Logger = stdLogger{}
+
+// SetLogger replace the logger of config package, default logger writes to
+// standard log package. Set to nil restores default logger.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdLogger{}
+	}
+	logger = l
+}
+
+func logDebug(msg string, fields ...Field) {
+	logger.Log(LevelDebug, msg, fields...)
+}
+
+func logInfo(msg string, fields ...Field) {
+	logger.Log(LevelInfo, msg, fields...)
+}
+
+func logWarn(msg string, fields ...Field) {
+	logger.Log(LevelWarn, msg, fields...)
+}
+
+func logError(msg string, fields ...Field) {
+	logger.Log(LevelError, msg, fields...)
+}
+
+func init() {
+	reset.Register(func() {
+		logger = stdLogger{}
+	}, nil)
+}
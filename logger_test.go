@@ -0,0 +1,63 @@
+package config_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/reset"
+)
+
+type logRec struct {
+	level  Level
+	msg    string
+	fields []Field
+}
+
+type recordLogger struct {
+	recs []logRec
+}
+
+func (l *recordLogger) Log(level Level, msg string, fields ...Field) {
+	l.recs = append(l.recs, logRec{level, msg, fields})
+}
+
+var _ = Describe("Logger", func() {
+
+	BeforeEach(func() {
+		reset.Enable()
+	})
+
+	AfterEach(func() {
+		ResetInternal()
+		reset.Disable()
+	})
+
+	It("Custom logger", func() {
+		l := &recordLogger{}
+		SetLogger(l)
+		Register("foo", newSimpleOption("bar"))
+		Ω(Load("/not/exist/app.conf")).Should(Succeed())
+
+		Ω(l.recs).Should(ContainElement(logRec{LevelDebug, "initing option", []Field{{"option", "foo"}}}))
+		Ω(l.recs).Should(ContainElement(logRec{LevelInfo, "loading config file", []Field{{"file", "/not/exist/app.conf"}}}))
+	})
+
+	It("slog adapter", func() {
+		buf := &bytes.Buffer{}
+		SetLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+		Ω(Load("/not/exist/app.conf")).Should(Succeed())
+
+		var rec map[string]interface{}
+		Ω(json.NewDecoder(buf).Decode(&rec)).Should(Succeed())
+		Ω(rec).Should(HaveKeyWithValue("level", "INFO"))
+		Ω(rec).Should(HaveKeyWithValue("pkg", "config"))
+		Ω(rec).Should(HaveKeyWithValue("msg", "loading config file"))
+		Ω(rec).Should(HaveKeyWithValue("file", "/not/exist/app.conf"))
+	})
+
+})
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/redforks/hal"
+	"github.com/redforks/life"
+)
+
+// OptionMap is a keyed collection of option instances of the same type,
+// registered by RegisterMap(). Each instance is a table named by its key,
+// such as [upstream.a] and [upstream.b] for option "upstream".
+type OptionMap map[string]Option
+
+// Init calls Init() of each instance in key order, abort on first error.
+func (m OptionMap) Init() error {
+	for _, k := range m.keys() {
+		if err := m[k].Init(); err != nil {
+			return fmt.Errorf("[%s] init '%s': %s", tag, k, err)
+		}
+	}
+	return nil
+}
+
+// Apply calls Apply() of each instance in key order. On reload, config
+// package calls Apply() only of changed instances, not this method.
+func (m OptionMap) Apply() {
+	for _, k := range m.keys() {
+		m[k].Apply()
+	}
+}
+
+func (m OptionMap) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MapCallback is called with key and instance when an instance of OptionMap
+// added or removed on reload.
+type MapCallback func(key string, op Option)
+
+type mapRec struct {
+	creator         OptionCreator
+	onAdd, onRemove MapCallback
+}
+
+// RegisterMap registers an OptionMap, each table under [name] in config file
+// is an instance created by fnCreateDefault and decoded from the table. No
+// instance exist by default.
+//
+// Instances are inited by Init() on load. On reload, new instances are inited
+// then passed to onAdd, removed instances passed to onRemove, changed
+// instances got Apply() calls, onAdd and onRemove can be nil. A new instance
+// failed to init is logged and skipped. Instances implement Validator are
+// validated each, the same as options.
+func RegisterMap(name string, fnCreateDefault OptionCreator, onAdd, onRemove MapCallback) {
+	checkOptionName(name)
+
+	life.EnsureStatef(life.Initing, "[%s] must register '%s' Option at life Initing phase", tag, name)
+
+	creator := func() Option {
+		return OptionMap{}
+	}
+	checkRegister(name, fnCreateDefault)
+	addOption(&optionRec{
+		name:    name,
+		creator: creator,
+		item:    &mapRec{fnCreateDefault, onAdd, onRemove},
+	})
+}
+
+// decodeMap decode instances of map option rec from sec into m, returns keys
+// not decoded.
+func decodeMap(rec *optionRec, sec map[string]interface{}, m OptionMap) ([]string, error) {
+	var unknown []string
+	for k, v := range sec {
+		table, ok := v.(map[string]interface{})
+		if !ok {
+			key := rec.name + "." + k
+			return nil, keyErrorf(key, "[%s] '%s' in config file should be a table", tag, key)
+		}
+
+		op := rec.item.creator()
+		md, err := decodeSection(rec.name+"."+k, table, op)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range md.Undecoded() {
+			unknown = append(unknown, rec.name+"."+k+"."+key.String())
+		}
+		m[k] = op
+	}
+	return unknown, nil
+}
+
+// applyMap applies changes from running to loaded instances of map option
+// rec, returns true if anything changed. Instances failed to init are removed
+// from loaded.
+func applyMap(rec *optionRec, running, loaded OptionMap) bool {
+	changed := false
+	for _, k := range running.keys() {
+		if _, exist := loaded[k]; exist {
+			continue
+		}
+		logInfo("option instance removed", Field{"option", rec.name}, Field{"key", k})
+		if rec.item.onRemove != nil {
+			rec.item.onRemove(k, running[k])
+		}
+		changed = true
+	}
+
+	for _, k := range loaded.keys() {
+		op := loaded[k]
+		old, exist := running[k]
+		if !exist {
+			if err := op.Init(); err != nil {
+				logError("init option instance failed", Field{"option", rec.name}, Field{"key", k}, Field{"err", err})
+				delete(loaded, k)
+				continue
+			}
+			logInfo("option instance added", Field{"option", rec.name}, Field{"key", k})
+			if rec.item.onAdd != nil {
+				rec.item.onAdd(k, op)
+			}
+			changed = true
+			continue
+		}
+
+		if keys := ChangedFields(old, op); len(keys) != 0 {
+			applyStart := hal.Now()
+			op.Apply()
+			logInfo("option applied", Field{"option", rec.name}, Field{"key", k}, Field{"keys", keys}, Field{"duration", hal.Now().Sub(applyStart)})
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,128 @@
+package config_test
+
+import (
+	"errors"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// upstreamOption records Init() and Apply() calls in upstreamEvents.
+type upstreamOption struct {
+	Addr string
+}
+
+var upstreamEvents []string
+
+func (o *upstreamOption) Init() error {
+	if o.Addr == "bad" {
+		return errors.New("bad addr")
+	}
+	upstreamEvents = append(upstreamEvents, "init "+o.Addr)
+	return nil
+}
+
+func (o *upstreamOption) Apply() {
+	upstreamEvents = append(upstreamEvents, "apply "+o.Addr)
+}
+
+var _ = Describe("OptionMap", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		upstreamEvents = nil
+
+		RegisterMap("upstream", func() Option {
+			return &upstreamOption{Addr: ":80"}
+		}, func(key string, op Option) {
+			upstreamEvents = append(upstreamEvents, "add "+key+" "+op.(*upstreamOption).Addr)
+		}, func(key string, op Option) {
+			upstreamEvents = append(upstreamEvents, "remove "+key+" "+op.(*upstreamOption).Addr)
+		})
+	})
+
+	It("Load", func() {
+		cfg.write(`[upstream.a]
+Addr = "a"
+
+[upstream.b]
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(upstreamEvents).Should(Equal([]string{"init a", "init :80"}))
+
+		opts, err := DecodeFile(cfg.file)
+		Ω(err).Should(Succeed())
+		Ω(opts["upstream"]).Should(Equal(OptionMap{
+			"a": &upstreamOption{Addr: "a"},
+			"b": &upstreamOption{Addr: ":80"},
+		}))
+	})
+
+	It("No instances by default", func() {
+		Ω(Load("")).Should(Succeed())
+		Ω(upstreamEvents).Should(BeEmpty())
+	})
+
+	It("Instance not table", func() {
+		cfg.write(`[upstream]
+a = 1
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] 'upstream.a' in config file should be a table (" + cfg.file + ":2)"))
+	})
+
+	It("Init error", func() {
+		cfg.write(`[upstream.a]
+Addr = "bad"
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] init 'a': bad addr"))
+	})
+
+	It("Reload", func() {
+		cfg.write(`[upstream.a]
+Addr = "a"
+
+[upstream.b]
+Addr = "b"
+
+[upstream.c]
+Addr = "c"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		upstreamEvents = nil
+
+		cfg.write(`[upstream.b]
+Addr = "b"
+
+[upstream.c]
+Addr = "c1"
+
+[upstream.d]
+Addr = "d"
+
+[upstream.e]
+Addr = "bad"
+`)
+		Reload()
+		Ω(upstreamEvents).Should(Equal([]string{
+			"remove a a",
+			"apply c1",
+			"init d",
+			"add d d",
+		}))
+
+		opts, err := DecodeFile(cfg.file)
+		Ω(err).Should(Succeed())
+		Ω(opts["upstream"]).Should(HaveLen(4))
+	})
+
+	It("Dump", func() {
+		Ω(DumpDefaultOptions()).Should(Equal(`# default options for test
+
+# [upstream]
+`))
+	})
+
+})
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/redforks/hal"
+	"github.com/redforks/testing/reset"
+)
+
+// LoadEvent describes a finished Load() or Reload().
+type LoadEvent struct {
+	// Reload is false for Load(), true for Reload().
+	Reload   bool
+	File     string
+	Duration time.Duration
+	// Err is nil if load/reload succeed.
+	Err error
+}
+
+// ApplyEvent describes a finished Apply() call of an option on reload.
+type ApplyEvent struct {
+	Option   string
+	Duration time.Duration
+}
+
+// Stats is a snapshot of config load and reload statistics.
+type Stats struct {
+	Reloads        uint64
+	ReloadFailures uint64
+
+	// LastSuccess is the time of last successful Load() or Reload(), zero if
+	// never succeed.
+	LastSuccess time.Time
+
+	// ApplyDurations is the duration of last Apply() call of each option.
+	ApplyDurations map[string]time.Duration
+
+	// Version of current effective config.
+	Version Version
+}
+
+var (
+	statsLock  sync.Mutex
+	stats      Stats
+	loadHooks  []func(LoadEvent)
+	applyHooks []func(ApplyEvent)
+)
+
+// AddLoadHook register a function called after each Load() and Reload(),
+// hooks are called in the goroutine doing load, should not block.
+func AddLoadHook(fn func(LoadEvent)) {
+	loadHooks = append(loadHooks, fn)
+}
+
+// AddApplyHook register a function called after each Apply() on reload.
+func AddApplyHook(fn func(ApplyEvent)) {
+	applyHooks = append(applyHooks, fn)
+}
+
+// GetStats returns a snapshot of load/reload statistics.
+func GetStats() Stats {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+
+	r := stats
+	r.Version = version
+	r.ApplyDurations = make(map[string]time.Duration, len(stats.ApplyDurations))
+	for k, v := range stats.ApplyDurations {
+		r.ApplyDurations[k] = v
+	}
+	return r
+}
+
+// PublishExpvar publish Stats as expvar variable name, such as "config".
+// Panics if name already published.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return GetStats()
+	}))
+}
+
+func notifyLoad(ev LoadEvent) {
+	statsLock.Lock()
+	if ev.Reload {
+		stats.Reloads++
+		if ev.Err != nil {
+			stats.ReloadFailures++
+		}
+	}
+	if ev.Err == nil {
+		stats.LastSuccess = hal.Now()
+	}
+	statsLock.Unlock()
+
+	for _, fn := range loadHooks {
+		fn(ev)
+	}
+}
+
+func notifyApply(ev ApplyEvent) {
+	statsLock.Lock()
+	if stats.ApplyDurations == nil {
+		stats.ApplyDurations = make(map[string]time.Duration)
+	}
+	stats.ApplyDurations[ev.Option] = ev.Duration
+	statsLock.Unlock()
+
+	for _, fn := range applyHooks {
+		fn(ev)
+	}
+}
+
+func init() {
+	reset.Register(func() {
+		stats = Stats{}
+		loadHooks = nil
+		applyHooks = nil
+	}, nil)
+}
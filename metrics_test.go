@@ -0,0 +1,112 @@
+package config_test
+
+import (
+	"expvar"
+	"strconv"
+	"time"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/hal"
+)
+
+var _ = Describe("Metrics", func() {
+
+	var (
+		cfg         = setupTestConfig()
+		loadEvents  []LoadEvent
+		applyEvents []ApplyEvent
+		now         time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		hal.Now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+
+		loadEvents, applyEvents = nil, nil
+		AddLoadHook(func(ev LoadEvent) {
+			loadEvents = append(loadEvents, ev)
+		})
+		AddApplyHook(func(ev ApplyEvent) {
+			applyEvents = append(applyEvents, ev)
+		})
+		Register("foo", newFakeOption(0))
+	})
+
+	It("Load", func() {
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(loadEvents).Should(Equal([]LoadEvent{{File: cfg.file, Duration: time.Second}}))
+		Ω(GetStats().LastSuccess).Should(Equal(now))
+		Ω(GetStats().Reloads).Should(BeZero())
+	})
+
+	It("Reload", func() {
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+		cfg.write(`[foo]
+Name = "foobar"
+`)
+		Reload()
+		Ω(loadEvents).Should(HaveLen(2))
+		Ω(loadEvents[1].Reload).Should(BeTrue())
+		Ω(loadEvents[1].Err).Should(BeNil())
+		Ω(applyEvents).Should(Equal([]ApplyEvent{{"foo", time.Second}}))
+
+		stats := GetStats()
+		Ω(stats.Reloads).Should(Equal(uint64(1)))
+		Ω(stats.ReloadFailures).Should(BeZero())
+		Ω(stats.ApplyDurations).Should(Equal(map[string]time.Duration{"foo": time.Second}))
+	})
+
+	It("Reload failed", func() {
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+		lastSuccess := GetStats().LastSuccess
+		cfg.write("[foo")
+		Reload()
+		Ω(loadEvents[1].Err).Should(HaveOccurred())
+
+		stats := GetStats()
+		Ω(stats.Reloads).Should(Equal(uint64(1)))
+		Ω(stats.ReloadFailures).Should(Equal(uint64(1)))
+		Ω(stats.LastSuccess).Should(Equal(lastSuccess))
+	})
+
+	It("Read stats while reloading", func() {
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		// run with -race to detect unguarded access
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				_ = GetStats()
+				_ = CurrentVersion()
+				_, _ = OptionVersion("foo")
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			cfg.write(`[foo]
+Name = "foo` + strconv.Itoa(i) + `"
+`)
+			Reload()
+		}
+		<-done
+		Ω(GetStats().Version.Generation).Should(Equal(uint64(11)))
+	})
+
+	It("Publish expvar", func() {
+		// expvar names are process wide, use unique name for go test -count
+		name := "config" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		PublishExpvar(name)
+		Ω(expvar.Get(name).String()).Should(ContainSubstring(`"Reloads":0`))
+	})
+
+})
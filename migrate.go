@@ -0,0 +1,225 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli"
+)
+
+// versionKey is the reserved key in option table stores its schema version,
+// the table is at schema version 0 if not exist.
+const versionKey = "_version"
+
+// Migration upgrades an option in config document from one schema version to
+// the next. A migration may change other options' tables, such as moving
+// fields between options.
+type Migration func(doc Document) error
+
+var (
+	migrateConfig bool
+
+	// FlagMigrateConfig rewrite config file to latest schema of all options
+	// and exit if enabled. Add to your cli.App.Flags.
+	FlagMigrateConfig cli.Flag = &cli.BoolFlag{
+		Name:        "migrateConfig",
+		Usage:       "Rewrite config file to latest schema and exit, comments in config file are lost",
+		Destination: &migrateConfig,
+	}
+)
+
+// RegisterMigrations declare schema migrations of registered option name.
+// migrations[i] upgrades the option table from schema version i to i+1, the
+// latest schema version is len(migrations). Panics if option not registered,
+// or migrations already registered.
+//
+// Old config files are migrated on load with a deprecation warning, use
+// MigrateFile() or FlagMigrateConfig to rewrite them.
+func RegisterMigrations(name string, migrations ...Migration) {
+	rec := findOption(name)
+	if rec == nil {
+		log.Panicf("[%s] register migrations of unknown option '%s'", tag, name)
+	}
+	if rec.migrations != nil {
+		log.Panicf("[%s] migrations of option '%s' already registered", tag, name)
+	}
+	rec.migrations = migrations
+}
+
+// RenameKey returns a Migration renames key old to new in option table.
+func RenameKey(option, old, new string) Migration {
+	return MoveKey(option, old, option, new)
+}
+
+// MoveKey returns a Migration moves key from one option table to another,
+// the target table created if not exist.
+func MoveKey(fromOption, fromKey, toOption, toKey string) Migration {
+	return func(doc Document) error {
+		from, err := doc.Section(fromOption)
+		if err != nil || from == nil {
+			return err
+		}
+
+		v, ok := from[fromKey]
+		if !ok {
+			return nil
+		}
+
+		to, err := doc.Section(toOption)
+		if err != nil {
+			return err
+		}
+		if to == nil {
+			// new table created by migration already at latest schema
+			to = make(map[string]interface{})
+			if rec := findOption(toOption); rec != nil && len(rec.migrations) != 0 {
+				to[versionKey] = int64(len(rec.migrations))
+			}
+			doc.setSection(toOption, to)
+		}
+		if _, exist := to[toKey]; exist {
+			return fmt.Errorf("[%s] migrate '%s.%s' to '%s.%s': target key already exist", tag, fromOption, fromKey, toOption, toKey)
+		}
+		delete(from, fromKey)
+		to[toKey] = v
+		return nil
+	}
+}
+
+// TransformValue returns a Migration replaces value of key in option table by
+// fn, not called if key not exist.
+func TransformValue(option, key string, fn func(v interface{}) (interface{}, error)) Migration {
+	return func(doc Document) error {
+		sec, err := doc.Section(option)
+		if err != nil || sec == nil {
+			return err
+		}
+
+		v, ok := sec[key]
+		if !ok {
+			return nil
+		}
+		if sec[key], err = fn(v); err != nil {
+			return fmt.Errorf("[%s] migrate '%s.%s': %s", tag, option, key, err)
+		}
+		return nil
+	}
+}
+
+func findOption(name string) *optionRec {
+	for _, rec := range options {
+		if rec.name == name {
+			return rec
+		}
+	}
+	return nil
+}
+
+func schemaVersion(sec map[string]interface{}) (int, error) {
+	v, ok := sec[versionKey]
+	if !ok {
+		return 0, nil
+	}
+
+	ver, ok := v.(int64)
+	if !ok || ver < 0 {
+		return 0, fmt.Errorf("[%s] bad schema version '%v'", tag, v)
+	}
+	return int(ver), nil
+}
+
+// migrateDocument upgrades all option tables in doc to their latest schema,
+// and remove schema version keys.
+func migrateDocument(doc Document) error {
+	for _, rec := range options {
+		sec, err := doc.Section(rec.name)
+		if err != nil {
+			return err
+		}
+		if sec == nil {
+			continue
+		}
+
+		ver, err := schemaVersion(sec)
+		if err != nil {
+			return fmt.Errorf("%s, option '%s'", err, rec.name)
+		}
+		latest := len(rec.migrations)
+		if ver > latest {
+			return fmt.Errorf("[%s] option '%s' schema version %d is newer than supported version %d", tag, rec.name, ver, latest)
+		}
+
+		if ver < latest {
+			logWarn("deprecated config schema, rewrite config file by --migrateConfig",
+				Field{"option", rec.name}, Field{"version", ver}, Field{"latest", latest})
+		}
+		for ; ver < latest; ver++ {
+			if err = rec.migrations[ver](doc); err != nil {
+				return err
+			}
+		}
+
+		// migration may replace the table
+		if sec, err = doc.Section(rec.name); err != nil {
+			return err
+		}
+		delete(sec, versionKey)
+	}
+	return nil
+}
+
+// MigrateFile rewrites config file at path to latest schema of all registered
+// options, including their tables in profiles. Comments and formatting of the
+// file are lost. If path is empty,
+// resolve config file the same way as Load().
+func MigrateFile(path string) error {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	path, err := resolveConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	doc, _, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+	// profiles merged before migration on load, into tables stamped latest
+	profiles, err := profileDocuments(doc)
+	if err != nil {
+		return err
+	}
+	for _, d := range append([]Document{doc}, profiles...) {
+		if err = resolveAliases(d); err != nil {
+			return err
+		}
+		if err = migrateDocument(d); err != nil {
+			return err
+		}
+
+		for _, rec := range options {
+			if len(rec.migrations) == 0 {
+				continue
+			}
+			if sec, _ := d.Section(rec.name); sec != nil {
+				sec[versionKey] = int64(len(rec.migrations))
+			}
+		}
+	}
+
+	buf := bytes.Buffer{}
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	if err = encoder.Encode(doc); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), info.Mode())
+}
@@ -0,0 +1,148 @@
+package config_test
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/matcher"
+)
+
+type AddrOption struct {
+	Addr    string
+	Timeout int
+}
+
+func (o *AddrOption) Init() error {
+	addrOp = o
+	return nil
+}
+
+func (o *AddrOption) Apply() {
+	addrOp = o
+}
+
+var addrOp *AddrOption
+
+var _ = Describe("Migrate", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+		Register("foo", func() Option {
+			return &SimpleOption{"bar"}
+		})
+		RegisterMigrations("server",
+			RenameKey("server", "Host", "Addr"),
+			MoveKey("foo", "Timeout", "server", "Timeout"),
+			TransformValue("server", "Timeout", func(v interface{}) (interface{}, error) {
+				return v.(int64) * 1000, nil
+			}),
+		)
+	})
+
+	It("Migrate old config", func() {
+		cfg.write(`[server]
+Host = ":8080"
+
+[foo]
+Name = "foobar"
+Timeout = 3
+`)
+		Ω(ValidateFile(cfg.file)).Should(Succeed())
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":8080", 3000}))
+	})
+
+	It("Latest version", func() {
+		cfg.write(`[server]
+_version = 3
+Addr = ":8080"
+Timeout = 5
+`)
+		Ω(ValidateFile(cfg.file)).Should(Succeed())
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":8080", 5}))
+	})
+
+	It("Partial migrated", func() {
+		cfg.write(`[server]
+_version = 2
+Addr = ":8080"
+Timeout = 5
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":8080", 5000}))
+	})
+
+	It("Newer version", func() {
+		cfg.write(`[server]
+_version = 4
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] option 'server' schema version 4 is newer than supported version 3"))
+	})
+
+	It("Rename conflict", func() {
+		cfg.write(`[server]
+Host = ":8080"
+Addr = ":8081"
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] migrate 'server.Host' to 'server.Addr': target key already exist"))
+	})
+
+	It("MigrateFile", func() {
+		cfg.write(`[server]
+Host = ":8080"
+
+[foo]
+Name = "foobar"
+Timeout = 3
+`)
+		Ω(MigrateFile(cfg.file)).Should(Succeed())
+		Ω(ioutil.ReadFile(cfg.file)).Should(BeEquivalentTo(`[foo]
+Name = "foobar"
+
+[server]
+Addr = ":8080"
+Timeout = 3000
+_version = 3
+`))
+	})
+
+	It("MigrateFile with profile", func() {
+		cfg.write(`[server]
+Host = ":8080"
+
+[profile.prod.server]
+Host = ":9090"
+`)
+		Ω(MigrateFile(cfg.file)).Should(Succeed())
+		Ω(ioutil.ReadFile(cfg.file)).Should(BeEquivalentTo(`[profile]
+[profile.prod]
+[profile.prod.server]
+Addr = ":9090"
+_version = 3
+
+[server]
+Addr = ":8080"
+_version = 3
+`))
+
+		SetProfile("prod")
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":9090"))
+	})
+
+	It("Register migrations of unknown option", func() {
+		Ω(func() {
+			RegisterMigrations("bar")
+		}).Should(matcher.Panics(fmt.Sprintf("[config] register migrations of unknown option 'bar'")))
+	})
+
+})
@@ -0,0 +1,124 @@
+package config_test
+
+import (
+	"encoding/json"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/matcher"
+)
+
+var _ = Describe("Nested", func() {
+
+	var (
+		cfg = setupTestConfig()
+		l   *recordLogger
+	)
+
+	BeforeEach(func() {
+		l = &recordLogger{}
+		SetLogger(l)
+
+		Register("db.primary", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+		Register("db.replica", func() Option {
+			return &SimpleOption{Name: "r"}
+		})
+	})
+
+	It("Load nested tables", func() {
+		cfg.write(`[db.primary]
+Addr = ":8080"
+
+[db.replica]
+Name = "foo"
+`)
+		opts, err := DecodeFile(cfg.file)
+		Ω(err).Should(Succeed())
+		Ω(opts["db.primary"]).Should(Equal(&AddrOption{Addr: ":8080"}))
+		Ω(opts["db.replica"]).Should(Equal(&SimpleOption{Name: "foo"}))
+	})
+
+	It("Unknown keys", func() {
+		cfg.write(`[db.primary]
+Host = "a"
+
+[db.backup]
+
+[cache]
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(l.recs).Should(ContainElement(logRec{LevelWarn, "unknown keys in config file are ignored, possibly wrong spelling",
+			[]Field{{"file", cfg.file}, {"keys", []string{"cache", "db.backup", "db.primary.Host"}}}}))
+	})
+
+	It("Parent not table", func() {
+		cfg.write(`db = 3
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] 'db' in config file should be a table (" + cfg.file + ":1)"))
+	})
+
+	It("Name conflicts", func() {
+		Ω(func() {
+			Register("db", newFakeOption(0))
+		}).Should(matcher.Panics("[config] option 'db' conflicts with 'db.primary', a table can not be both option and parent of options"))
+		Ω(func() {
+			Register("db.primary.pool", newFakeOption(0))
+		}).Should(matcher.Panics("[config] option 'db.primary.pool' conflicts with 'db.primary', a table can not be both option and parent of options"))
+		Ω(func() {
+			Register("profile.db", newFakeOption(0))
+		}).Should(matcher.Panics("[config] option name 'profile.db' is reserved for profiles, rename the option"))
+	})
+
+	It("Dump", func() {
+		Ω(DumpDefaultOptions()).Should(Equal(`# default options for test
+
+# [db]
+# [db.primary]
+# Addr = ":80"
+# Timeout = 0
+# [db.replica]
+# Name = "r"
+`))
+	})
+
+	It("Alias", func() {
+		RegisterAlias("primary", "db.primary")
+		cfg.write(`[primary]
+Addr = ":8080"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{Addr: ":8080"}))
+	})
+
+	It("Interpolate", func() {
+		cfg.write(`[db.replica]
+Name = "${db.primary.Addr}"
+`)
+		opts, err := DecodeFile(cfg.file)
+		Ω(err).Should(Succeed())
+		Ω(opts["db.replica"]).Should(Equal(&SimpleOption{Name: ":80"}))
+	})
+
+	It("JSON schema", func() {
+		data, err := JSONSchema()
+		Ω(err).Should(Succeed())
+
+		var s struct {
+			Properties map[string]struct {
+				Type       string
+				Properties map[string]interface{}
+			}
+		}
+		Ω(json.Unmarshal(data, &s)).Should(Succeed())
+		Ω(s.Properties).Should(HaveLen(2))
+		Ω(s.Properties).Should(HaveKey("profile"))
+		Ω(s.Properties["db"].Type).Should(Equal("object"))
+		Ω(s.Properties["db"].Properties).Should(HaveKey("primary"))
+		Ω(s.Properties["db"].Properties).Should(HaveKey("replica"))
+	})
+
+})
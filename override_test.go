@@ -0,0 +1,82 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OverrideScope", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80", Timeout: 3}
+		})
+	})
+
+	It("Field", func() {
+		s := NewOverrideScope().Field("server", "Timeout", 5)
+		Ω(Load("")).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":80", 5}))
+		Ω(s.Close()).Should(Succeed())
+	})
+
+	It("Option", func() {
+		s := NewOverrideScope().Option("server", &AddrOption{Addr: ":8080"})
+		Ω(Load("")).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":8080", 0}))
+		Ω(s.Close()).Should(Succeed())
+	})
+
+	It("Reload", func() {
+		Register("foo", newFakeOption(0))
+		op := &FakeOption{Name: "one"}
+		s := NewOverrideScope().Option("foo", op)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(ops[0].Name).Should(Equal("one"))
+
+		cfg.write(`[foo]
+Name = "two"
+`)
+		Reload()
+		Ω(applyHits[0]).Should(Equal(1))
+		Ω(ops[0].Name).Should(Equal("two"))
+		Ω(op.Name).Should(Equal("one"), "scoped instance not changed")
+		Ω(s.Close()).Should(Succeed())
+	})
+
+	It("Set twice", func() {
+		s := NewOverrideScope().Field("server", "Timeout", 5).Field("server", "Timeout", 6)
+		Ω(Load("")).Should(Succeed())
+		Ω(addrOp.Timeout).Should(Equal(6))
+		Ω(s.Close()).Should(Succeed())
+	})
+
+	It("Nested", func() {
+		outer := NewOverrideScope().Field("server", "Timeout", 5).Field("server", "Addr", ":1")
+		inner := NewOverrideScope().Field("server", "Timeout", 6)
+		Ω(Load("")).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":1", 6}))
+
+		Ω(outer.Close()).Should(MatchError("[config] close OverrideScope not innermost"))
+		Ω(inner.Close()).Should(Succeed())
+
+		Unload()
+		Ω(Load("")).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":1", 5}))
+		Ω(outer.Close()).Should(Succeed())
+	})
+
+	It("Report unused at close", func() {
+		s := NewOverrideScope().
+			Field("server", "Bad", 5).
+			Field("wrong", "Timeout", 5).
+			Option("wrongOption", &AddrOption{})
+		Ω(Load("")).Should(Succeed())
+		Ω(s.Close()).Should(MatchError("[config] overrides not used, wrong option name or field? server.Bad, wrong.Timeout, wrongOption"))
+	})
+
+})
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/redforks/testing/reset"
+)
+
+// PermAction decides what to do if a file violates PermPolicy.
+type PermAction int
+
+const (
+	// PermIgnore disables permission checks.
+	PermIgnore PermAction = iota
+
+	// PermWarn logs a warning and continues loading.
+	PermWarn
+
+	// PermFail fails Load() and Reload().
+	PermFail
+)
+
+// PermPolicy is the permission and ownership policy of config file and secret
+// files it references, such as key file of KeyFile(). Group or world writable
+// files always violate the policy.
+type PermPolicy struct {
+	Action PermAction
+
+	AllowGroupRead bool
+	AllowWorldRead bool
+
+	// Owners are allowed uids of file owner, empty allows any owner.
+	Owners []int
+}
+
+var permPolicy PermPolicy
+
+// SetPermPolicy set permission policy of config file, default to PermIgnore.
+func SetPermPolicy(p PermPolicy) {
+	permPolicy = p
+}
+
+// checkFilePerm checks file at path by permPolicy, returns error if violated
+// and policy action is PermFail.
+func checkFilePerm(path string) error {
+	if permPolicy.Action == PermIgnore {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	mode := info.Mode().Perm()
+	if mode&0022 != 0 {
+		problems = append(problems, "group or world writable")
+	}
+	if mode&0040 != 0 && !permPolicy.AllowGroupRead {
+		problems = append(problems, "group readable")
+	}
+	if mode&0004 != 0 && !permPolicy.AllowWorldRead {
+		problems = append(problems, "world readable")
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && len(permPolicy.Owners) != 0 && !containsInt(permPolicy.Owners, int(st.Uid)) {
+		problems = append(problems, fmt.Sprintf("owned by unexpected uid %d", st.Uid))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	if permPolicy.Action == PermWarn {
+		logWarn("unsafe file permission", Field{"file", path}, Field{"mode", mode}, Field{"problems", problems})
+		return nil
+	}
+	return fmt.Errorf("[%s] unsafe file permission of '%s' (%s): %s", tag, path, mode, strings.Join(problems, ", "))
+}
+
+func containsInt(l []int, v int) bool {
+	for _, item := range l {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	reset.Register(func() {
+		permPolicy = PermPolicy{}
+	}, nil)
+}
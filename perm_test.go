@@ -0,0 +1,81 @@
+package config_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Permission", func() {
+
+	var (
+		cfg = setupTestConfig()
+
+		writeFile = func(path string, content string, mode os.FileMode) {
+			Ω(ioutil.WriteFile(path, []byte(content), mode)).Should(Succeed())
+			Ω(os.Chmod(path, mode)).Should(Succeed())
+		}
+	)
+
+	BeforeEach(func() {
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+	})
+
+	It("Ignore by default", func() {
+		writeFile(cfg.file, "", 0666)
+		Ω(Load(cfg.file)).Should(Succeed())
+	})
+
+	It("Safe", func() {
+		SetPermPolicy(PermPolicy{Action: PermFail, Owners: []int{os.Getuid()}})
+		writeFile(cfg.file, "", 0600)
+		Ω(Load(cfg.file)).Should(Succeed())
+	})
+
+	It("Fail", func() {
+		SetPermPolicy(PermPolicy{Action: PermFail})
+		writeFile(cfg.file, "", 0666)
+		Ω(Load(cfg.file)).Should(MatchError(fmt.Sprintf("[config] unsafe file permission of '%s' (-rw-rw-rw-): group or world writable, group readable, world readable", cfg.file)))
+	})
+
+	It("Allow read", func() {
+		SetPermPolicy(PermPolicy{Action: PermFail, AllowGroupRead: true, AllowWorldRead: true})
+		writeFile(cfg.file, "", 0644)
+		Ω(Load(cfg.file)).Should(Succeed())
+	})
+
+	It("Unexpected owner", func() {
+		SetPermPolicy(PermPolicy{Action: PermFail, Owners: []int{os.Getuid() + 1}})
+		writeFile(cfg.file, "", 0600)
+		Ω(Load(cfg.file)).Should(MatchError(ContainSubstring(fmt.Sprintf("owned by unexpected uid %d", os.Getuid()))))
+	})
+
+	It("Warn", func() {
+		l := &recordLogger{}
+		SetLogger(l)
+		SetPermPolicy(PermPolicy{Action: PermWarn})
+		writeFile(cfg.file, "", 0640)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(l.recs).Should(ContainElement(logRec{LevelWarn, "unsafe file permission",
+			[]Field{{"file", cfg.file}, {"mode", os.FileMode(0640)}, {"problems", []string{"group readable"}}}}))
+	})
+
+	It("Key file", func() {
+		SetPermPolicy(PermPolicy{Action: PermFail})
+		keyFile := cfg.path("key")
+		key, err := GenerateKey()
+		Ω(err).Should(Succeed())
+		writeFile(keyFile, key, 0644)
+		SetKeyProvider(KeyFile(keyFile))
+		_, err = EncryptValue("foo")
+		Ω(err).Should(MatchError(ContainSubstring("world readable")))
+	})
+
+})
@@ -3,12 +3,13 @@
 //  1. Each package do not need store and parse configuration
 //  2. Application only need one line code to get configuration support, no
 //     matter how many packages pulled.
-//  3. Currently support configuration file only, later can store configuration
-//     in a dedicate config server, suitable for micro service structure.
+//  3. Support configuration file, or fetch it from a dedicate config server
+//     by HTTPSource, suitable for micro service structure.
 //  4. Monitor SIGUSR1, on SIGUSR1 reload configuration file, and apply to each
 //     package.
 //  5. Works with life package in mind. Only allow first init in life.Initing
-//     phase, and not reload configuration in Shutingdown phase.
+//     phase, and not reload configuration in Shutingdown phase. Plugins loaded
+//     later use RegisterLate().
 //
 // Note: no order and dependency sort, although in current implementation,
 // Init() called by Register order, which probabbly is go package dependency
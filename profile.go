@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/redforks/hal"
+	"github.com/redforks/testing/reset"
+	"github.com/urfave/cli"
+)
+
+const (
+	// profilesKey is the reserved top level table of config file holds
+	// profiles, such as [profile.prod.foo] overrides [foo] in prod profile.
+	profilesKey = "profile"
+
+	// ProfileEnv is the environment variable selects profile, if not set by
+	// FlagProfile or SetProfile().
+	ProfileEnv = "CONFIG_PROFILE"
+)
+
+var (
+	profile string
+
+	// FlagProfile select profile in config file, add to your cli.App.Flags.
+	FlagProfile cli.Flag = &cli.StringFlag{
+		Name:        "profile",
+		Usage:       "select profile in config file, such as prod, [profile.prod.foo] overrides [foo]",
+		Destination: &profile,
+	}
+)
+
+// SetProfile select the profile in config file, must be called before Load().
+// Empty name means no profile.
+func SetProfile(name string) {
+	profile = name
+}
+
+// ActiveProfile returns selected profile, by FlagProfile, SetProfile() or
+// ProfileEnv environment variable. Returns empty string if no profile
+// selected.
+func ActiveProfile() string {
+	if profile != "" {
+		return profile
+	}
+	return hal.Getenv(ProfileEnv)
+}
+
+// applyProfile merge tables of active profile into doc, and remove all
+// profiles from doc. Keys set by the profile are added to profiled.
+func applyProfile(doc Document, profiled map[string]bool) error {
+	profiles, err := doc.Section(profilesKey)
+	if err != nil {
+		return err
+	}
+	delete(doc, profilesKey)
+
+	name := ActiveProfile()
+	if name == "" {
+		return nil
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("[%s] profile '%s' not defined in config file", tag, name)
+	}
+	prof, ok := p.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("[%s] profile '%s' should be a table", tag, name)
+	}
+
+	logInfo("apply profile", Field{"profile", name})
+	return mergeTable(doc, prof, "", profiled)
+}
+
+// profileDocuments returns tables of each profile in doc as documents, in
+// profile name order, they share content with doc.
+func profileDocuments(doc Document) ([]Document, error) {
+	profiles, err := doc.Section(profilesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := make([]Document, len(names))
+	for i, name := range names {
+		prof, ok := profiles[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("[%s] profile '%s' should be a table", tag, name)
+		}
+		r[i] = prof
+	}
+	return r, nil
+}
+
+// mergeTable merge src into dst recursively, values in src override dst.
+// Keys of values and tables set to dst are added to set.
+func mergeTable(dst, src map[string]interface{}, prefix string, set map[string]bool) error {
+	for k, v := range src {
+		srcTable, srcIsTable := v.(map[string]interface{})
+		if !srcIsTable {
+			dst[k] = v
+			set[prefix+k] = true
+			continue
+		}
+
+		old, exist := dst[k]
+		if !exist {
+			dst[k] = srcTable
+			set[prefix+k] = true
+			continue
+		}
+		dstTable, ok := old.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("[%s] profile can not override '%s%s' by a table", tag, prefix, k)
+		}
+		if err := mergeTable(dstTable, srcTable, prefix+k+".", set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReservedName panics if name is under profiles table.
+func checkReservedName(name string) {
+	if splitName(name)[0] == profilesKey {
+		log.Panicf("[%s] option name '%s' is reserved for profiles, rename the option", tag, name)
+	}
+}
+
+func init() {
+	reset.Register(func() {
+		profile = ""
+	}, nil)
+}
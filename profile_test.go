@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/hal"
+	"github.com/redforks/testing/matcher"
+)
+
+var _ = Describe("Profile", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80", Timeout: 3}
+		})
+		cfg.write(`[server]
+Addr = ":8080"
+Timeout = 5
+
+[profile.prod.server]
+Addr = ":443"
+
+[profile.dev.server]
+Timeout = 60
+`)
+	})
+
+	It("No profile", func() {
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":8080", 5}))
+	})
+
+	It("By SetProfile", func() {
+		SetProfile("prod")
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":443", 5}))
+		Ω(DumpEffectiveOptions()).Should(ContainSubstring("# profile: prod\n"))
+	})
+
+	It("By environment variable", func() {
+		hal.Getenv = func(name string) string {
+			if name == ProfileEnv {
+				return "dev"
+			}
+			return ""
+		}
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(*addrOp).Should(Equal(AddrOption{":8080", 60}))
+	})
+
+	It("Profile not defined", func() {
+		SetProfile("staging")
+		Ω(Load(cfg.file)).Should(MatchError("[config] profile 'staging' not defined in config file"))
+	})
+
+	It("Reserved name", func() {
+		Ω(func() {
+			Register("profile", newSimpleOption("bar"))
+		}).Should(matcher.Panics("[config] option name 'profile' is reserved for profiles, rename the option"))
+	})
+
+})
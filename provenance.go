@@ -0,0 +1,360 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceKind tells where value of an option field comes from.
+type SourceKind int
+
+const (
+	// SourceDefault is value created by option creator passed to Register().
+	SourceDefault SourceKind = iota
+	// SourceOverride is value overridden for tests, by OverrideScope or
+	// overridden default options.
+	SourceOverride
+	// SourceFile is value set in config file.
+	SourceFile
+	// SourceProfile is value set by active profile in config file.
+	SourceProfile
+	// SourceHTTP is value set in config file fetched by HTTPSource.
+	SourceHTTP
+)
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceDefault:
+		return "default"
+	case SourceOverride:
+		return "override"
+	case SourceFile:
+		return "file"
+	case SourceProfile:
+		return "profile"
+	case SourceHTTP:
+		return "http"
+	}
+	return fmt.Sprintf("SourceKind(%d)", int(k))
+}
+
+// Provenance tells where value of an option field comes from.
+type Provenance struct {
+	Kind SourceKind
+
+	// File is config file path, or URL of HTTPSource, empty if value not from
+	// config file.
+	File string
+
+	// Line is line number in config file, 0 if unknown, such as value of
+	// renamed key by alias or migration.
+	Line int
+
+	// Profile is active profile name if Kind is SourceProfile.
+	Profile string
+}
+
+// String returns provenance in "file /etc/app.conf:12" form.
+func (p Provenance) String() string {
+	s := p.Kind.String()
+	if p.Profile != "" {
+		s += " " + p.Profile
+	}
+	if loc := p.location(); loc != "" {
+		s += " " + loc
+	}
+	return s
+}
+
+// location returns "file:line" of the value, empty if not from file.
+func (p Provenance) location() string {
+	if p.File == "" || p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// FieldProvenance returns where value of field key of currently applied
+// option comes from, key in "DB.Host" form as in config file. A key not set
+// individually gets provenance of its nearest parent table set, or the option
+// default. Elements of arrays of tables are keyed by index, such as
+// "Items.1.Name". Returns false if option not registered or not inited.
+func FieldProvenance(option, key string) (Provenance, bool) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	rec := findOption(option)
+	if rec == nil || rec.sources == nil {
+		return Provenance{}, false
+	}
+	return rec.sources.lookup(key), true
+}
+
+// OptionProvenance returns provenance of all fields of currently applied
+// option, keyed by field keys in "DB.Host" form, tables are expanded to their
+// fields. Returns false if option not registered or not inited.
+func OptionProvenance(option string) (map[string]Provenance, bool) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	rec := findOption(option)
+	if rec == nil || rec.sources == nil {
+		return nil, false
+	}
+
+	doc, err := optionDocument(rec.op)
+	if err != nil {
+		logWarn("can not get option fields", Field{"option", option}, Field{"err", err})
+		return nil, false
+	}
+
+	r := make(map[string]Provenance)
+	for _, key := range leafKeys(doc, "") {
+		r[key] = rec.sources.lookup(key)
+	}
+	return r, true
+}
+
+// optionSources records provenance of fields of an option instance.
+type optionSources struct {
+	// provenance of fields not in fields
+	base Provenance
+
+	// keyed by field key, such as "DB.Host"
+	fields map[string]Provenance
+}
+
+func newOptionSources(kind SourceKind) *optionSources {
+	return &optionSources{base: Provenance{Kind: kind}, fields: make(map[string]Provenance)}
+}
+
+func (s *optionSources) lookup(key string) Provenance {
+	for {
+		if p, ok := s.fields[key]; ok {
+			return p
+		}
+		n := strings.LastIndex(key, ".")
+		if n < 0 {
+			return s.base
+		}
+		key = key[:n]
+	}
+}
+
+// leafKeys returns dotted keys of non-table values in m, sorted. An array of
+// tables is a value, its elements are keyed by index, such as "Items.1", and
+// their values such as "Items.1.Name".
+func leafKeys(m map[string]interface{}, prefix string) []string {
+	var r []string
+	for k, v := range m {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if len(val) != 0 {
+				r = append(r, leafKeys(val, prefix+k+".")...)
+				continue
+			}
+		case []map[string]interface{}:
+			for i, item := range val {
+				key := prefix + k + "." + strconv.Itoa(i)
+				r = append(r, key)
+				r = append(r, leafKeys(item, key+".")...)
+			}
+		}
+		r = append(r, prefix+k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// fileSource is the config file being decoded, locates keys in it.
+type fileSource struct {
+	kind SourceKind
+	name string // file path, or URL of HTTPSource
+
+	// line numbers of keys and tables in "db.primary.Addr" form
+	lines map[string]int
+
+	// keys set by active profile, and the profile
+	profiled map[string]bool
+	profile  string
+
+	// file content, to decode options registered after load
+	data []byte
+}
+
+func newFileSource(path string, data []byte) *fileSource {
+	f := &fileSource{kind: SourceFile, name: path, lines: scanLines(data), profiled: make(map[string]bool), data: data}
+	if httpSource != nil && path == httpSource.CacheFile {
+		f.kind, f.name = SourceHTTP, httpSource.URL
+	}
+	return f
+}
+
+// line returns line number of key or its nearest parent table, 0 if unknown.
+func (f *fileSource) line(key string) int {
+	for {
+		if n, ok := f.lines[key]; ok {
+			return n
+		}
+		n := strings.LastIndex(key, ".")
+		if n < 0 {
+			return 0
+		}
+		key = key[:n]
+	}
+}
+
+// provenance returns provenance of key in "option.Key" form.
+func (f *fileSource) provenance(key string) Provenance {
+	for k := key; ; {
+		if f.profiled[k] {
+			return Provenance{SourceProfile, f.name, f.line(profilesKey + "." + f.profile + "." + key), f.profile}
+		}
+		n := strings.LastIndex(k, ".")
+		if n < 0 {
+			break
+		}
+		k = k[:n]
+	}
+	return Provenance{Kind: f.kind, File: f.name, Line: f.line(key)}
+}
+
+// locate returns " (file:line)" suffix of error message about key, empty if
+// f is nil.
+func (f *fileSource) locate(key string) string {
+	if f == nil {
+		return ""
+	}
+	return " (" + f.provenance(key).location() + ")"
+}
+
+// record sets provenance of fields in option tables of doc to srcs, srcs are
+// in options order.
+func (f *fileSource) record(doc Document, srcs []*optionSources) error {
+	for i, rec := range options {
+		if err := f.recordOption(doc, rec, srcs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordOption sets provenance of fields in table of rec in doc to src.
+func (f *fileSource) recordOption(doc Document, rec *optionRec, src *optionSources) error {
+	sec, err := doc.Section(rec.name)
+	if err != nil {
+		return err
+	}
+	for _, key := range leafKeys(sec, "") {
+		src.fields[key] = f.provenance(rec.name + "." + key)
+	}
+	return nil
+}
+
+var (
+	tableLine = regexp.MustCompile(`^\[\[?\s*([^\[\]]+?)\s*\]\]?\s*(#.*)?$`)
+	keyLine   = regexp.MustCompile(`^((?:[A-Za-z0-9_-]+|"[^"]*"|'[^']*')(?:\s*\.\s*(?:[A-Za-z0-9_-]+|"[^"]*"|'[^']*'))*)\s*=`)
+)
+
+// scanLines returns line numbers of tables and keys in config file content,
+// keyed by dotted path. Only the first line of each path recorded, values
+// inside multi-line strings are skipped. Elements of arrays of tables are
+// keyed by index, such as "foo.Items.1.Name", the same as leafKeys().
+func scanLines(data []byte) map[string]int {
+	r := make(map[string]int)
+	table := ""
+	inString := ""
+	// count of elements of arrays of tables, keyed by indexed path
+	elements := make(map[string]int)
+	// indexed returns path with the last element index after each array
+	indexed := func(path string) string {
+		var key string
+		for _, k := range strings.Split(path, ".") {
+			key = joinKey(key, k)
+			if n := elements[key]; n != 0 {
+				key += "." + strconv.Itoa(n-1)
+			}
+		}
+		return key
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if inString != "" {
+			if strings.Count(line, inString)%2 == 1 {
+				inString = ""
+			}
+			continue
+		}
+
+		if m := tableLine.FindStringSubmatch(line); m != nil {
+			path := keyPath(m[1])
+			if strings.HasPrefix(line, "[[") {
+				// new element of the array, the array itself not indexed
+				array := path
+				if i := strings.LastIndex(path, "."); i >= 0 {
+					array = joinKey(indexed(path[:i]), path[i+1:])
+				}
+				if _, exist := r[array]; !exist {
+					r[array] = n
+				}
+				elements[array]++
+				table = array + "." + strconv.Itoa(elements[array]-1)
+			} else {
+				table = indexed(path)
+			}
+			if _, exist := r[table]; !exist {
+				r[table] = n
+			}
+			continue
+		}
+
+		m := keyLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := joinKey(table, keyPath(m[1]))
+		if _, exist := r[key]; !exist {
+			r[key] = n
+		}
+
+		for _, quote := range []string{`"""`, `'''`} {
+			if strings.Count(line, quote)%2 == 1 {
+				inString = quote
+			}
+		}
+	}
+	return r
+}
+
+// keyPath normalizes dotted key, removes quotes and spaces around dots.
+func keyPath(s string) string {
+	var (
+		parts []string
+		buf   strings.Builder
+		quote rune
+	)
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				buf.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, strings.TrimSpace(buf.String()))
+			buf.Reset()
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	return strings.Join(append(parts, strings.TrimSpace(buf.String())), ".")
+}
@@ -0,0 +1,142 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provenance", func() {
+
+	var (
+		cfg = setupTestConfig()
+
+		fieldProvenance = func(option, key string) Provenance {
+			p, ok := FieldProvenance(option, key)
+			Ω(ok).Should(BeTrue())
+			return p
+		}
+	)
+
+	BeforeEach(func() {
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80", Timeout: 3}
+		})
+	})
+
+	It("Not inited", func() {
+		_, ok := FieldProvenance("server", "Addr")
+		Ω(ok).Should(BeFalse())
+		_, ok = OptionProvenance("foo")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("Default and file", func() {
+		cfg.write(`# comment
+[server]
+Desc = """
+Addr = ":1"
+"""
+Addr = ":8080"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(fieldProvenance("server", "Addr")).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 6}))
+		Ω(fieldProvenance("server", "Timeout")).Should(Equal(Provenance{Kind: SourceDefault}))
+
+		all, ok := OptionProvenance("server")
+		Ω(ok).Should(BeTrue())
+		Ω(all).Should(Equal(map[string]Provenance{
+			"Addr":    {Kind: SourceFile, File: cfg.file, Line: 6},
+			"Timeout": {Kind: SourceDefault},
+		}))
+	})
+
+	It("Profile", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+
+[profile.prod.server]
+Timeout = 5
+`)
+		SetProfile("prod")
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(fieldProvenance("server", "Addr")).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 2}))
+		Ω(fieldProvenance("server", "Timeout")).Should(Equal(Provenance{Kind: SourceProfile, File: cfg.file, Line: 5, Profile: "prod"}))
+		Ω(fieldProvenance("server", "Timeout").String()).Should(Equal("profile prod " + cfg.file + ":5"))
+	})
+
+	It("Override", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+`)
+		s := NewOverrideScope().Field("server", "Timeout", 9)
+		defer func() {
+			Ω(s.Close()).Should(Succeed())
+		}()
+
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(fieldProvenance("server", "Timeout")).Should(Equal(Provenance{Kind: SourceOverride}))
+		Ω(fieldProvenance("server", "Timeout").String()).Should(Equal("override"))
+	})
+
+	It("Reload", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		cfg.write(`[server]
+
+Timeout = 5
+`)
+		Reload()
+		Ω(fieldProvenance("server", "Addr")).Should(Equal(Provenance{Kind: SourceDefault}))
+		Ω(fieldProvenance("server", "Timeout")).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 3}))
+	})
+
+	It("Effective dump", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(DumpEffectiveOptions()).Should(HaveSuffix(`[server]
+Addr = ":8080" # file ` + cfg.file + `:2
+Timeout = 3
+`))
+	})
+
+	It("Effective dump of nested tables", func() {
+		Register("hook", newHookOption)
+		cfg.write(`[hook.Quotas]
+"a.b" = 1024
+
+[[hook.Backends]]
+Timeout = "1s"
+
+[[hook.Backends]]
+Timeout = "2s"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(fieldProvenance("hook", "Backends")).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 4}))
+		Ω(fieldProvenance("hook", "Backends.1")).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 7}))
+		Ω(fieldProvenance("hook", "Backends.1.Timeout")).Should(Equal(Provenance{Kind: SourceFile, File: cfg.file, Line: 8}))
+		Ω(DumpEffectiveOptions()).Should(ContainSubstring(`Name = "foo"
+[hook.Quotas]
+"a.b" = "1KiB" # file ` + cfg.file + `:2
+[hook.Primary]
+Timeout = "1h0m0s"
+
+[[hook.Backends]]
+Timeout = "1s" # file ` + cfg.file + `:5
+
+[[hook.Backends]]
+Timeout = "2s" # file ` + cfg.file + `:8
+
+[server]
+Addr = ":80"
+Timeout = 3
+`))
+	})
+
+})
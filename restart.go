@@ -0,0 +1,179 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/redforks/testing/reset"
+)
+
+// RestartRequired is an optional interface an Option implements if none of
+// its fields can be applied at runtime, such as listen address or data
+// directory. Changing such option in config file takes effect after restart.
+//
+// To mark individual fields as restart-required, tag them with
+// `config:"restart"` instead.
+type RestartRequired interface {
+	RestartRequired() bool
+}
+
+// configTag is the struct tag key of config flags, such as `config:"restart"`.
+const configTag = "config"
+
+// RestartPolicy controls how Reload() handles changes to restart-required
+// options and fields.
+type RestartPolicy int
+
+const (
+	// WarnRestartRequired logs a warning, and still calls Apply() of the
+	// changed option. Restart-required fields should be ignored by Apply().
+	WarnRestartRequired RestartPolicy = iota
+
+	// RefuseRestartRequired logs a warning, and not apply the option at all,
+	// the option keeps its current value until restart.
+	RefuseRestartRequired
+)
+
+var (
+	restartPolicy RestartPolicy
+
+	// keys of restart-required options/fields changed since Init(), in
+	// registration order.
+	restartPending []string
+)
+
+// SetRestartPolicy set how Reload() handles changed restart-required options,
+// default to WarnRestartRequired.
+func SetRestartPolicy(p RestartPolicy) {
+	restartPolicy = p
+}
+
+// RestartPending returns keys of restart-required options and fields that
+// changed in config file since application start, such as "foo" for the whole
+// option, or "foo.Addr" for a field. Returns nil if nothing pending.
+func RestartPending() []string {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	return append([]string(nil), restartPending...)
+}
+
+// restartFields returns keys of restart-required options/fields that differ
+// between running and newly loaded option.
+func restartFields(name string, running, loaded Option) []string {
+	if running == nil {
+		return nil
+	}
+
+	if m, ok := loaded.(OptionMap); ok {
+		// instances added after start not running, no restart needed
+		var r []string
+		for _, k := range m.keys() {
+			r = append(r, restartFields(name+"."+k, running.(OptionMap)[k], m[k])...)
+		}
+		return r
+	}
+
+	if r, ok := loaded.(RestartRequired); ok && r.RestartRequired() {
+		if optionChanged(running, loaded) {
+			return []string{name}
+		}
+		return nil
+	}
+
+	return diffRestartFields(name, reflect.ValueOf(running), reflect.ValueOf(loaded), nil)
+}
+
+func diffRestartFields(prefix string, v1, v2 reflect.Value, r []string) []string {
+	if v1.Kind() == reflect.Ptr && v2.Kind() == reflect.Ptr && v1.IsNil() != v2.IsNil() {
+		// sub table added or removed, changed if it holds restart fields
+		if hasRestartFields(v1.Type()) {
+			r = append(r, prefix)
+		}
+		return r
+	}
+
+	v1, v2 = reflect.Indirect(v1), reflect.Indirect(v2)
+	if !v1.IsValid() || !v2.IsValid() || v1.Kind() != reflect.Struct || v1.Type() != v2.Type() {
+		return r
+	}
+
+	t := v1.Type()
+	for _, hf := range structFields(t) {
+		key := prefix + "." + hf.key
+		f1, f2 := fieldByIndex(v1, hf.index, false), fieldByIndex(v2, hf.index, false)
+		if hasConfigFlag(t.FieldByIndex(hf.index), "restart") {
+			if valueChanged(f1, f2) {
+				r = append(r, key)
+			}
+			continue
+		}
+		if f1.IsValid() != f2.IsValid() {
+			// embedded struct pointer nil on one side
+			if hasRestartFields(hf.typ) {
+				r = append(r, key)
+			}
+			continue
+		}
+		r = diffRestartFields(key, f1, f2, r)
+	}
+	return r
+}
+
+// hasRestartFields returns true if struct type t, or pointer to it, has
+// restart-required fields, including fields of sub structs.
+func hasRestartFields(t reflect.Type) bool {
+	return findRestartFields(t, make(map[reflect.Type]bool))
+}
+
+func findRestartFields(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	for _, hf := range structFields(t) {
+		if hasConfigFlag(t.FieldByIndex(hf.index), "restart") || findRestartFields(hf.typ, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldKey returns the key of struct field in config file, returns false if
+// the field not exported or ignored by toml tag.
+func fieldKey(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	name := strings.Split(f.Tag.Get("toml"), ",")[0]
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+// hasConfigFlag returns true if flag exist in `config:"..."` tag of the field,
+// flags are comma separated.
+func hasConfigFlag(f reflect.StructField, flag string) bool {
+	for _, s := range strings.Split(f.Tag.Get(configTag), ",") {
+		if strings.TrimSpace(s) == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	reset.Register(func() {
+		restartPolicy = WarnRestartRequired
+		restartPending = nil
+	}, nil)
+}
@@ -0,0 +1,224 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type ServerOption struct {
+	Addr    string `config:"restart"`
+	Timeout int
+
+	applied *int
+}
+
+func (o *ServerOption) Init() error {
+	return nil
+}
+
+func (o *ServerOption) Apply() {
+	*o.applied++
+}
+
+type ListenOption struct {
+	Addr string `config:"restart"`
+}
+
+type ProxyOption struct {
+	Listen *ListenOption
+
+	applied *int
+}
+
+func (o *ProxyOption) Init() error {
+	return nil
+}
+
+func (o *ProxyOption) Apply() {
+	*o.applied++
+}
+
+type EmbeddedListenOption struct {
+	ListenOption
+	Timeout int
+
+	applied *int
+}
+
+func (o *EmbeddedListenOption) Init() error {
+	return nil
+}
+
+func (o *EmbeddedListenOption) Apply() {
+	*o.applied++
+}
+
+type DataDirOption struct {
+	Dir string
+
+	applied *int
+}
+
+func (o *DataDirOption) Init() error {
+	return nil
+}
+
+func (o *DataDirOption) Apply() {
+	*o.applied++
+}
+
+func (o *DataDirOption) RestartRequired() bool {
+	return true
+}
+
+var _ = Describe("Restart required", func() {
+
+	var (
+		cfg     = setupTestConfig()
+		applied int
+	)
+
+	BeforeEach(func() {
+		applied = 0
+
+		Register("server", func() Option {
+			return &ServerOption{Addr: ":80", Timeout: 3, applied: &applied}
+		})
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+	})
+
+	It("Not pending if hot field changed", func() {
+		cfg.write(`[server]
+Timeout = 5
+`)
+		Reload()
+		Ω(applied).Should(Equal(1))
+		Ω(RestartPending()).Should(BeEmpty())
+	})
+
+	It("Warn and apply", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+`)
+		Reload()
+		Ω(applied).Should(Equal(1))
+		Ω(RestartPending()).Should(Equal([]string{"server.Addr"}))
+	})
+
+	It("Refuse", func() {
+		SetRestartPolicy(RefuseRestartRequired)
+		cfg.write(`[server]
+Addr = ":8080"
+Timeout = 5
+`)
+		Reload()
+		Ω(applied).Should(Equal(0))
+		Ω(RestartPending()).Should(Equal([]string{"server.Addr"}))
+		Ω(DumpEffectiveOptions()).Should(Equal(`# effective options for test
+# version: generation 1, sha256 ` + CurrentVersion().Hash + `
+# restart pending: server.Addr
+
+[server]
+Addr = ":80"
+Timeout = 3
+`))
+	})
+
+	It("Pending cleared if reverted", func() {
+		cfg.write(`[server]
+Addr = ":8080"
+`)
+		Reload()
+		cfg.write(``)
+		Reload()
+		Ω(RestartPending()).Should(BeEmpty())
+	})
+
+})
+
+var _ = Describe("Restart required field in pointer sub struct", func() {
+
+	var (
+		cfg     = setupTestConfig()
+		applied int
+	)
+
+	BeforeEach(func() {
+		applied = 0
+
+		Register("proxy", func() Option {
+			return &ProxyOption{applied: &applied}
+		})
+	})
+
+	It("Sub table removed", func() {
+		cfg.write(`[proxy.Listen]
+Addr = ":80"
+`)
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		cfg.write("")
+		Reload()
+		Ω(applied).Should(Equal(1))
+		Ω(RestartPending()).Should(Equal([]string{"proxy.Listen"}))
+	})
+
+	It("Sub table added", func() {
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		cfg.write(`[proxy.Listen]
+Addr = ":80"
+`)
+		Reload()
+		Ω(applied).Should(Equal(1))
+		Ω(RestartPending()).Should(Equal([]string{"proxy.Listen"}))
+	})
+
+})
+
+var _ = Describe("Restart required field in embedded struct", func() {
+
+	var cfg = setupTestConfig()
+
+	It("Flattened", func() {
+		applied := 0
+		Register("listen", func() Option {
+			return &EmbeddedListenOption{ListenOption{":80"}, 3, &applied}
+		})
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		cfg.write(`[listen]
+Addr = ":8080"
+Timeout = 5
+`)
+		Reload()
+		Ω(applied).Should(Equal(1))
+		Ω(RestartPending()).Should(Equal([]string{"listen.Addr"}))
+	})
+
+})
+
+var _ = Describe("Restart required option", func() {
+
+	var cfg = setupTestConfig()
+
+	It("Whole option", func() {
+		applied := 0
+		Register("data", func() Option {
+			return &DataDirOption{Dir: "/var/lib/app", applied: &applied}
+		})
+		Ω(Load(cfg.file)).Should(Succeed())
+
+		cfg.write(`[data]
+Dir = "/tmp"
+`)
+		Reload()
+		Ω(applied).Should(Equal(1))
+		Ω(RestartPending()).Should(Equal([]string{"data"}))
+	})
+
+})
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redforks/appinfo"
+	"github.com/urfave/cli"
+)
+
+var (
+	dumpJSONSchema bool
+
+	// FlagDumpJSONSchema dump JSON Schema of config file to stdout if enabled,
+	// add to your cli.App.Flags.
+	FlagDumpJSONSchema cli.Flag = &cli.BoolFlag{
+		Name:        "dumpJSONSchema",
+		Usage:       "Dump JSON Schema of config file to stdout, for editors and linters",
+		Destination: &dumpJSONSchema,
+	}
+
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// JSONSchema returns JSON Schema (draft-07) of config file, generated from
+// default values of registered options.
+//
+// Struct field tags add more information to the schema:
+//
+//	desc:"..."     description of the field
+//	min:"1"        minimum value of a number field
+//	max:"10"       maximum value of a number field
+//	enum:"a,b,c"   allowed values, comma separated
+func JSONSchema() ([]byte, error) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	props := make(map[string]interface{}, len(options))
+	for name, op := range getDefaultOptionKVs() {
+		rec := findOption(name)
+		if rec.item != nil {
+			op = rec.item.creator()
+		}
+		s, err := valueSchema(reflect.ValueOf(op))
+		if err != nil {
+			return nil, fmt.Errorf("[%s] option '%s': %s", tag, name, err)
+		}
+		if rec.item != nil {
+			s = map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{},
+				"additionalProperties": s,
+			}
+		}
+		if len(rec.migrations) != 0 {
+			s["properties"].(map[string]interface{})[versionKey] = map[string]interface{}{
+				"type":    "integer",
+				"maximum": len(rec.migrations),
+			}
+		}
+		setSchemaPath(props, splitName(name), s)
+	}
+
+	// each profile overrides option tables, such as [profile.prod.foo]
+	profileProps := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		profileProps[k] = v
+	}
+	props[profilesKey] = map[string]interface{}{
+		"type": "object",
+		"additionalProperties": map[string]interface{}{
+			"type":                 "object",
+			"properties":           profileProps,
+			"additionalProperties": false,
+		},
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                appinfo.CodeName() + " config",
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}, "", "  ")
+}
+
+// setSchemaPath set schema s of hierarchical option name path in props,
+// creates object schemas of parent tables if not exist.
+func setSchemaPath(props map[string]interface{}, path []string, s map[string]interface{}) {
+	for _, p := range path[:len(path)-1] {
+		parent, ok := props[p].(map[string]interface{})
+		if !ok {
+			parent = map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{},
+				"additionalProperties": false,
+			}
+			props[p] = parent
+		}
+		props = parent["properties"].(map[string]interface{})
+	}
+	props[path[len(path)-1]] = s
+}
+
+func valueSchema(v reflect.Value) (map[string]interface{}, error) {
+	if _, ok := typeHooks[v.Type()]; ok {
+		// nil pointers and slices not encoded by hook, have no default
+		s := map[string]interface{}{"type": "string"}
+		if def, err := encodeHooked(v); err == nil && def.IsValid() && def.Type() != v.Type() {
+			s["default"] = def.Interface()
+		}
+		return s, nil
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return typeSchema(v.Type())
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || v.Type() == timeType || v.Type().Implements(textMarshalerType) {
+		s, err := typeSchema(v.Type())
+		if err == nil {
+			if def, ok := schemaDefault(v); ok {
+				s["default"] = def
+			}
+		}
+		return s, err
+	}
+
+	t := v.Type()
+	props := make(map[string]interface{}, t.NumField())
+	for _, hf := range structFields(t) {
+		f := t.FieldByIndex(hf.index)
+		var (
+			s   map[string]interface{}
+			err error
+		)
+		// fields of nil embedded struct pointer have no default
+		if fv := fieldByIndex(v, hf.index, false); fv.IsValid() {
+			s, err = valueSchema(fv)
+		} else {
+			s, err = typeSchema(hf.typ)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %s", f.Name, err)
+		}
+		if err = applyFieldTags(s, f); err != nil {
+			return nil, fmt.Errorf("field '%s': %s", f.Name, err)
+		}
+		props[hf.key] = s
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}, nil
+}
+
+func typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	if _, ok := typeHooks[t]; ok {
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": items}, nil
+	case reflect.Struct:
+		return valueSchema(reflect.New(t).Elem())
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// schemaDefault returns default value of v in JSON compatible form.
+func schemaDefault(v reflect.Value) (interface{}, bool) {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err == nil
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), true
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return v.Interface(), true
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+	return nil, false
+}
+
+func applyFieldTags(s map[string]interface{}, f reflect.StructField) error {
+	desc := f.Tag.Get("desc")
+	if hasConfigFlag(f, "restart") {
+		desc = strings.TrimSpace(desc + " (restart required)")
+	}
+	if desc != "" {
+		s["description"] = desc
+	}
+
+	for _, key := range []string{"min", "max"} {
+		if v := f.Tag.Get(key); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("bad %s tag '%s'", key, v)
+			}
+			s[key+"imum"] = n
+		}
+	}
+
+	if v := f.Tag.Get("enum"); v != "" {
+		var enum []interface{}
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if s["type"] == "integer" || s["type"] == "number" {
+				n, err := strconv.ParseFloat(item, 64)
+				if err != nil {
+					return fmt.Errorf("bad enum tag '%s'", v)
+				}
+				enum = append(enum, n)
+			} else {
+				enum = append(enum, item)
+			}
+		}
+		s["enum"] = enum
+	}
+	return nil
+}
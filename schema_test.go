@@ -0,0 +1,175 @@
+package config_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/reset"
+)
+
+type SchemaOption struct {
+	Addr    string `desc:"listen address" config:"restart"`
+	Workers int    `min:"1" max:"64"`
+	Mode    string `enum:"dev,prod"`
+	Tags    []string
+	Started time.Time
+	DB      struct {
+		URL string `toml:"url"`
+	}
+
+	ignored int
+}
+
+type SchemaBase struct {
+	Host string `desc:"host name"`
+}
+
+type EmbeddedSchemaOption struct {
+	SchemaBase
+	Port int
+}
+
+func (o *EmbeddedSchemaOption) Init() error {
+	return nil
+}
+
+func (o *EmbeddedSchemaOption) Apply() {
+}
+
+func (o *SchemaOption) Init() error {
+	return nil
+}
+
+func (o *SchemaOption) Apply() {
+}
+
+var _ = Describe("JSONSchema", func() {
+
+	BeforeEach(func() {
+		reset.Enable()
+	})
+
+	AfterEach(func() {
+		ResetInternal()
+		reset.Disable()
+	})
+
+	It("Generate", func() {
+		Register("server", func() Option {
+			return &SchemaOption{Addr: ":80", Workers: 4, Mode: "prod"}
+		})
+		RegisterMigrations("server", RenameKey("server", "Host", "Addr"))
+
+		buf, err := JSONSchema()
+		Ω(err).Should(Succeed())
+		Ω(buf).Should(MatchJSON(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "test config",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "profile": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "server": {
+            "type": "object",
+            "additionalProperties": false,
+            "properties": {
+              "Addr": {"type": "string", "default": ":80", "description": "listen address (restart required)"},
+              "Workers": {"type": "integer", "default": 4, "minimum": 1, "maximum": 64},
+              "Mode": {"type": "string", "default": "prod", "enum": ["dev", "prod"]},
+              "Tags": {"type": "array", "items": {"type": "string"}},
+              "Started": {"type": "string", "format": "date-time", "default": "0001-01-01T00:00:00Z"},
+              "DB": {
+                "type": "object",
+                "additionalProperties": false,
+                "properties": {
+                  "url": {"type": "string", "default": ""}
+                }
+              },
+              "_version": {"type": "integer", "maximum": 1}
+            }
+          }
+        }
+      }
+    },
+    "server": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "Addr": {"type": "string", "default": ":80", "description": "listen address (restart required)"},
+        "Workers": {"type": "integer", "default": 4, "minimum": 1, "maximum": 64},
+        "Mode": {"type": "string", "default": "prod", "enum": ["dev", "prod"]},
+        "Tags": {"type": "array", "items": {"type": "string"}},
+        "Started": {"type": "string", "format": "date-time", "default": "0001-01-01T00:00:00Z"},
+        "DB": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "url": {"type": "string", "default": ""}
+          }
+        },
+        "_version": {"type": "integer", "maximum": 1}
+      }
+    }
+  }
+}`))
+	})
+
+	It("Embedded struct flattened", func() {
+		Register("server", func() Option {
+			return &EmbeddedSchemaOption{SchemaBase{"localhost"}, 80}
+		})
+
+		buf, err := JSONSchema()
+		Ω(err).Should(Succeed())
+		Ω(buf).Should(MatchJSON(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "test config",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "profile": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "server": {
+            "type": "object",
+            "additionalProperties": false,
+            "properties": {
+              "Host": {"type": "string", "default": "localhost", "description": "host name"},
+              "Port": {"type": "integer", "default": 80}
+            }
+          }
+        }
+      }
+    },
+    "server": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "Host": {"type": "string", "default": "localhost", "description": "host name"},
+        "Port": {"type": "integer", "default": 80}
+      }
+    }
+  }
+}`))
+	})
+
+	It("Valid JSON without options", func() {
+		buf, err := JSONSchema()
+		Ω(err).Should(Succeed())
+		var v map[string]interface{}
+		Ω(json.Unmarshal(buf, &v)).Should(Succeed())
+	})
+
+})
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/redforks/appinfo"
+	"github.com/redforks/testing/reset"
+	"github.com/redforks/xdgdirs"
+	"github.com/urfave/cli"
+)
+
+var (
+	searchPath []string
+	fileNames  []string
+
+	showConfigSearch bool
+
+	// FlagShowConfigSearch print paths tried to find config file and exit if
+	// enabled, add to your cli.App.Flags.
+	FlagShowConfigSearch cli.Flag = &cli.BoolFlag{
+		Name:        "showConfigSearch",
+		Usage:       "Print paths tried to find config file, and which one is used",
+		Destination: &showConfigSearch,
+	}
+)
+
+// SetSearchPath set directories to find config file, in order. Default to
+// DefaultSearchPath(), calling without arguments restores default. Such as:
+//
+//	config.SetSearchPath(".", config.EtcDir(), xdgdirs.ConfigHome())
+func SetSearchPath(dirs ...string) {
+	searchPath = dirs
+}
+
+// SetFileNames set config file names to find in each search directory, in
+// order, such as "foo.conf", "foo.toml". Default to DefaultFileNames(),
+// calling without arguments restores default.
+func SetFileNames(names ...string) {
+	fileNames = names
+}
+
+// DefaultSearchPath returns xdg config directories.
+func DefaultSearchPath() []string {
+	return xdgdirs.ConfigDirs()
+}
+
+// DefaultFileNames returns app code name with ".conf" extension.
+func DefaultFileNames() []string {
+	return []string{appinfo.CodeName() + ".conf"}
+}
+
+// EtcDir returns "/etc/<app code name>".
+func EtcDir() string {
+	return filepath.Join("/etc", appinfo.CodeName())
+}
+
+// Probe is a path tried to find config file.
+type Probe struct {
+	Path  string
+	Found bool
+
+	// Err is not nil if path exist but not usable, such as a directory.
+	Err error
+}
+
+// DiscoveryReport lists every path tried to find config file, and the found
+// one.
+type DiscoveryReport struct {
+	Probes []Probe
+
+	// Found is the config file found, empty if not found.
+	Found string
+}
+
+func (r DiscoveryReport) String() string {
+	buf := strings.Builder{}
+	for _, p := range r.Probes {
+		switch {
+		case p.Found:
+			fmt.Fprintf(&buf, "%s: found\n", p.Path)
+		case p.Err != nil:
+			fmt.Fprintf(&buf, "%s: %s\n", p.Path, p.Err)
+		default:
+			fmt.Fprintf(&buf, "%s: not exist\n", p.Path)
+		}
+	}
+	if r.Found == "" {
+		buf.WriteString("config file not found, use default options\n")
+	}
+	return buf.String()
+}
+
+// Discover find config file in search path, stops at the first found file.
+func Discover() DiscoveryReport {
+	dirs, names := searchPath, fileNames
+	if dirs == nil {
+		dirs = DefaultSearchPath()
+	}
+	if names == nil {
+		names = DefaultFileNames()
+	}
+
+	r := DiscoveryReport{}
+	for _, dir := range dirs {
+		for _, name := range names {
+			p := Probe{Path: filepath.Join(dir, name)}
+			info, err := os.Stat(p.Path)
+			switch {
+			case err != nil:
+				if !os.IsNotExist(err) {
+					p.Err = err
+				}
+			case !info.Mode().IsRegular():
+				p.Err = fmt.Errorf("not a regular file")
+			default:
+				p.Found = true
+				r.Found = p.Path
+			}
+
+			r.Probes = append(r.Probes, p)
+			if p.Found {
+				return r
+			}
+		}
+	}
+	return r
+}
+
+func init() {
+	reset.Register(func() {
+		searchPath = nil
+		fileNames = nil
+	}, nil)
+}
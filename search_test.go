@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Search path", func() {
+
+	var (
+		cfg        = setupTestConfig()
+		dir1, dir2 string
+	)
+
+	BeforeEach(func() {
+		dir1 = cfg.path("a")
+		dir2 = cfg.path("b")
+		Ω(os.Mkdir(dir1, 0700)).Should(Succeed())
+		Ω(os.Mkdir(dir2, 0700)).Should(Succeed())
+
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+		SetSearchPath(dir1, dir2)
+		SetFileNames("test.conf", "test.toml")
+	})
+
+	It("Not found", func() {
+		r := Discover()
+		Ω(r.Found).Should(BeEmpty())
+		Ω(r.Probes).Should(Equal([]Probe{
+			{Path: filepath.Join(dir1, "test.conf")},
+			{Path: filepath.Join(dir1, "test.toml")},
+			{Path: filepath.Join(dir2, "test.conf")},
+			{Path: filepath.Join(dir2, "test.toml")},
+		}))
+		Ω(Load("")).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":80"))
+	})
+
+	It("Found", func() {
+		Ω(os.Mkdir(filepath.Join(dir1, "test.toml"), 0700)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(dir2, "test.conf"), []byte(`[server]
+Addr = ":8080"
+`), 0600)).Should(Succeed())
+
+		r := Discover()
+		Ω(r.Found).Should(Equal(filepath.Join(dir2, "test.conf")))
+		Ω(r.Probes).Should(HaveLen(3))
+		Ω(r.Probes[1].Err).Should(MatchError("not a regular file"))
+		Ω(r.String()).Should(Equal(filepath.Join(dir1, "test.conf") + ": not exist\n" +
+			filepath.Join(dir1, "test.toml") + ": not a regular file\n" +
+			filepath.Join(dir2, "test.conf") + ": found\n"))
+
+		Ω(Load("")).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":8080"))
+	})
+
+	It("Default", func() {
+		SetSearchPath()
+		SetFileNames()
+		Ω(DefaultFileNames()).Should(Equal([]string{"test.conf"}))
+		Ω(Discover().Probes[0].Path).Should(Equal(filepath.Join(DefaultSearchPath()[0], "test.conf")))
+		Ω(EtcDir()).Should(Equal("/etc/test"))
+	})
+
+})
@@ -0,0 +1,67 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SwitchFile", func() {
+
+	var (
+		cfg         = setupTestConfig()
+		blue, green string
+	)
+
+	BeforeEach(func() {
+		blue = cfg.path("blue.conf")
+		green = cfg.path("green.conf")
+
+		Register("foo", newFakeOption(0))
+		writeConfigFile(blue, `[foo]
+Name = "blue"
+`)
+		writeConfigFile(green, `[foo]
+Name = "green"
+`)
+	})
+
+	It("Before Load", func() {
+		Ω(SwitchFile(green)).Should(MatchError("[config] can not switch config file before Load()"))
+	})
+
+	It("Switch", func() {
+		Ω(Load(blue)).Should(Succeed())
+		Ω(SwitchFile(green)).Should(Succeed())
+		Ω(ops[0].Name).Should(Equal("green"))
+		Ω(applyHits[0]).Should(Equal(1))
+
+		// Reload reads new file
+		writeConfigFile(green, `[foo]
+Name = "green1"
+`)
+		Reload()
+		Ω(ops[0].Name).Should(Equal("green1"))
+	})
+
+	It("File not exist", func() {
+		Ω(Load(blue)).Should(Succeed())
+		Ω(SwitchFile(cfg.path("not-exist.conf"))).ShouldNot(Succeed())
+		Ω(applyHits[0]).Should(Equal(0))
+	})
+
+	It("Bad file keeps current", func() {
+		Ω(Load(blue)).Should(Succeed())
+		writeConfigFile(green, "[foo")
+		Ω(SwitchFile(green)).ShouldNot(Succeed())
+		Ω(applyHits[0]).Should(Equal(0))
+
+		writeConfigFile(blue, `[foo]
+Name = "blue1"
+`)
+		Reload()
+		Ω(ops[0].Name).Should(Equal("blue1"))
+	})
+
+})
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+)
+
+// Unload discards loaded options, so that Load() can be called again, such as
+// CLI tools processing several config files in one run. Config file set by
+// Load() or Flag is kept, call Load() with the new file.
+//
+// Load() after Unload() works the same as the first Load(): creates new
+// option instances and calls their Init(), options must tolerate Init()
+// called again. Options are not notified on Unload(), Reload() is ignored
+// until next Load(). Versions keep increasing across Unload().
+func Unload() {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	optionsLock.Lock()
+	loaded, inited = false, false
+	appliedFile = nil
+	restartPending = nil
+	for _, rec := range options {
+		rec.op, rec.initOp, rec.sources = nil, nil, nil
+	}
+	optionsLock.Unlock()
+	logInfo("options unloaded")
+}
+
+// DecodeFile decodes config file at path into new option instances, keyed by
+// option name, without calling Init() or Apply(), current options not
+// affected. Returns error if file not exist, or any option failed to decode
+// or validate. Can be called at any time, such as by a tool converting many
+// config files.
+func DecodeFile(path string) (map[string]Option, error) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	opts := newOptions()
+	keys, file, err := decodeConfigFile(path, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 0 {
+		logWarn("unknown keys in config file are ignored, possibly wrong spelling", Field{"file", path}, Field{"keys", keys})
+	}
+	if err = validateOptions(opts, file); err != nil {
+		return nil, err
+	}
+
+	r := make(map[string]Option, len(opts))
+	for i, rec := range options {
+		r[rec.name] = opts[i]
+	}
+	return r, nil
+}
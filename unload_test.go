@@ -0,0 +1,84 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unload", func() {
+
+	var (
+		cfg          = setupTestConfig()
+		file1, file2 string
+	)
+
+	BeforeEach(func() {
+		file1 = cfg.path("1.conf")
+		file2 = cfg.path("2.conf")
+
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+		writeConfigFile(file1, `[server]
+Addr = ":1"
+`)
+		writeConfigFile(file2, `[server]
+Addr = ":2"
+`)
+	})
+
+	It("Load again", func() {
+		Ω(Load(file1)).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":1"))
+		first := addrOp
+
+		Unload()
+		Ω(Load(file2)).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":2"))
+		Ω(addrOp).ShouldNot(BeIdenticalTo(first))
+		Ω(CurrentVersion().Generation).Should(Equal(uint64(2)))
+	})
+
+	It("Reload ignored after Unload", func() {
+		Register("foo", newFakeOption(0))
+		RegisterMap("upstream", func() Option {
+			return &upstreamOption{}
+		}, nil, nil)
+		Ω(Load(file1)).Should(Succeed())
+		Unload()
+
+		writeConfigFile(file1, `[foo]
+Name = "foobar"
+
+[upstream.a]
+Addr = ":1"
+`)
+		Reload()
+		Ω(initHits[0]).Should(Equal(1))
+		Ω(applyHits[0]).Should(Equal(0))
+		Ω(addrOp.Addr).Should(Equal(":1"))
+	})
+
+	It("Load same file", func() {
+		Ω(Load(file1)).Should(Succeed())
+		Unload()
+		Ω(Load("")).Should(Succeed())
+		Ω(addrOp.Addr).Should(Equal(":1"))
+	})
+
+	It("DecodeFile", func() {
+		Ω(Load(file1)).Should(Succeed())
+		opts, err := DecodeFile(file2)
+		Ω(err).Should(Succeed())
+		Ω(opts).Should(Equal(map[string]Option{"server": &AddrOption{Addr: ":2"}}))
+		Ω(addrOp.Addr).Should(Equal(":1"))
+	})
+
+	It("DecodeFile not exist", func() {
+		_, err := DecodeFile(cfg.path("not-exist.conf"))
+		Ω(err).Should(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Validator is an optional interface an Option implements to check its values
+// after decoded from config file. Load() and Reload() fail if Validate()
+// returns error, ValidateFile() reports it. Instances of OptionMap are
+// validated each.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError returned by ValidateFile(), contains all problems found in
+// the config file.
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("[%s] config file '%s' invalid:\n  %s", tag, e.File, strings.Join(e.Problems, "\n  "))
+}
+
+// validateOptions runs Validate() of opts, errors are located in file if not
+// nil.
+func validateOptions(opts []Option, file *fileSource) error {
+	for i, rec := range options {
+		if err := validateOption(rec, opts[i], file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateOption runs Validate() of op of rec if implemented, returns the
+// first error.
+func validateOption(rec *optionRec, op Option, file *fileSource) (err error) {
+	validateEach(rec, op, func(name string, e error) {
+		if err == nil {
+			err = fmt.Errorf("[%s] option '%s' invalid: %s%s", tag, name, e, file.locate(name))
+		}
+	})
+	return err
+}
+
+// validateEach runs Validate() of op of rec if implemented, each instance of
+// OptionMap validated separately, named such as "upstream.a". Calls fn with
+// name and error of each invalid one.
+func validateEach(rec *optionRec, op Option, fn func(name string, err error)) {
+	if m, ok := op.(OptionMap); ok {
+		for _, k := range m.keys() {
+			if err := validate(m[k]); err != nil {
+				fn(rec.name+"."+k, err)
+			}
+		}
+		return
+	}
+
+	if err := validate(op); err != nil {
+		fn(rec.name, err)
+	}
+}
+
+func validate(op Option) error {
+	if v, ok := op.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// ValidateFile checks config file at path without applying it: parse the file,
+// decode all registered options, run their Validate(), and reports unknown
+// keys. If path is empty, resolve config file the same way as Load().
+//
+// Returns *ValidationError if the file parsed but has problems.
+func ValidateFile(path string) error {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+
+	path, err := resolveConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(path); err != nil {
+		return err
+	}
+
+	opts := newOptions()
+	keys, file, err := decodeConfigFile(path, opts, nil)
+	if err != nil {
+		return err
+	}
+
+	vErr := &ValidationError{File: path}
+	for _, key := range keys {
+		vErr.Problems = append(vErr.Problems, fmt.Sprintf("unknown key '%s'%s", key, file.locate(key)))
+	}
+	for i, rec := range options {
+		validateEach(rec, opts[i], func(name string, err error) {
+			vErr.Problems = append(vErr.Problems, fmt.Sprintf("option '%s': %s%s", name, err, file.locate(name)))
+		})
+	}
+
+	if len(vErr.Problems) != 0 {
+		return vErr
+	}
+	return nil
+}
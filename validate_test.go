@@ -0,0 +1,133 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/errors"
+)
+
+type PortOption struct {
+	Port int
+}
+
+func (o *PortOption) Init() error {
+	return nil
+}
+
+func (o *PortOption) Apply() {
+}
+
+func (o *PortOption) Validate() error {
+	if o.Port <= 0 {
+		return errors.New("Port must be positive")
+	}
+	return nil
+}
+
+var _ = Describe("Validate", func() {
+
+	var cfg = setupTestConfig()
+
+	BeforeEach(func() {
+		Register("web", func() Option {
+			return &PortOption{80}
+		})
+		Register("foo", newSimpleOption("bar"))
+	})
+
+	It("Valid", func() {
+		cfg.write(`[web]
+Port = 8080
+`)
+		Ω(ValidateFile(cfg.file)).Should(Succeed())
+	})
+
+	It("File not exist", func() {
+		Ω(ValidateFile(cfg.file)).Should(HaveOccurred())
+	})
+
+	It("Syntax error", func() {
+		cfg.write("[web")
+		Ω(ValidateFile(cfg.file)).Should(HaveOccurred())
+	})
+
+	It("Report all problems", func() {
+		cfg.write(`[web]
+Port = -1
+Host = "localhost"
+
+[bar]
+Name = "foo"
+`)
+		err := ValidateFile(cfg.file)
+		Ω(err).Should(BeAssignableToTypeOf(&ValidationError{}))
+		Ω(err.(*ValidationError).Problems).Should(ConsistOf(
+			"unknown key 'web.Host' ("+cfg.file+":3)",
+			"unknown key 'bar' ("+cfg.file+":5)",
+			"option 'web': Port must be positive ("+cfg.file+":1)",
+		))
+	})
+
+	It("Load fails on invalid option", func() {
+		cfg.write(`[web]
+Port = -1
+`)
+		Ω(Load(cfg.file)).Should(MatchError("[config] option 'web' invalid: Port must be positive (" + cfg.file + ":1)"))
+	})
+
+	Context("Option map", func() {
+
+		BeforeEach(func() {
+			RegisterMap("ports", func() Option {
+				return &PortOption{80}
+			}, nil, nil)
+			cfg.write(`[ports.a]
+Port = 8080
+
+[ports.b]
+Port = -1
+`)
+		})
+
+		It("Load", func() {
+			Ω(Load(cfg.file)).Should(MatchError("[config] option 'ports.b' invalid: Port must be positive (" + cfg.file + ":4)"))
+		})
+
+		It("ValidateFile", func() {
+			err := ValidateFile(cfg.file)
+			Ω(err).Should(BeAssignableToTypeOf(&ValidationError{}))
+			Ω(err.(*ValidationError).Problems).Should(Equal([]string{
+				"option 'ports.b': Port must be positive (" + cfg.file + ":4)",
+			}))
+		})
+
+		It("DecodeFile", func() {
+			_, err := DecodeFile(cfg.file)
+			Ω(err).Should(MatchError(ContainSubstring("option 'ports.b' invalid")))
+		})
+
+		It("Reload", func() {
+			cfg.write("")
+			Ω(Load(cfg.file)).Should(Succeed())
+			cfg.write(`[ports.a]
+Port = -1
+`)
+			Reload()
+			Ω(GetStats().ReloadFailures).Should(Equal(uint64(1)))
+		})
+
+	})
+
+	It("Reload keeps old option on invalid option", func() {
+		cfg.write("")
+		Ω(Load(cfg.file)).Should(Succeed())
+		cfg.write(`[web]
+Port = -1
+`)
+		Reload()
+		Ω(GetStats().ReloadFailures).Should(Equal(uint64(1)))
+	})
+
+})
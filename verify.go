@@ -0,0 +1,90 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/redforks/testing/reset"
+)
+
+// Verifier checks integrity of config file content before it is loaded. Load()
+// fails and Reload() keeps current options if verify failed.
+type Verifier interface {
+	Verify(path string, data []byte) error
+}
+
+// VerifierFunc adapts a function to Verifier.
+type VerifierFunc func(path string, data []byte) error
+
+// Verify calls f(path, data).
+func (f VerifierFunc) Verify(path string, data []byte) error {
+	return f(path, data)
+}
+
+var verifier Verifier
+
+// SetVerifier enables verification of config file, set to nil to disable.
+func SetVerifier(v Verifier) {
+	verifier = v
+}
+
+// ChecksumVerifier returns a Verifier checks config file by sha256 checksum
+// in file path + ".sha256", in hex, the format of sha256sum command output is
+// also accepted.
+func ChecksumVerifier() Verifier {
+	return VerifierFunc(func(path string, data []byte) error {
+		buf, err := ioutil.ReadFile(path + ".sha256")
+		if err != nil {
+			return fmt.Errorf("[%s] verify '%s': %s", tag, path, err)
+		}
+
+		fields := strings.Fields(string(buf))
+		if len(fields) == 0 {
+			return fmt.Errorf("[%s] verify '%s': empty checksum file", tag, path)
+		}
+		exp, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return fmt.Errorf("[%s] verify '%s': bad checksum file: %s", tag, path, err)
+		}
+
+		sum := sha256.Sum256(data)
+		if subtle.ConstantTimeCompare(exp, sum[:]) != 1 {
+			return fmt.Errorf("[%s] verify '%s': checksum mismatch", tag, path)
+		}
+		return nil
+	})
+}
+
+// Ed25519Verifier returns a Verifier checks config file by detached ed25519
+// signature in file path + ".sig", the signature is raw 64 bytes, or in base64.
+func Ed25519Verifier(pub ed25519.PublicKey) Verifier {
+	return VerifierFunc(func(path string, data []byte) error {
+		sig, err := ioutil.ReadFile(path + ".sig")
+		if err != nil {
+			return fmt.Errorf("[%s] verify '%s': %s", tag, path, err)
+		}
+
+		if len(sig) != ed25519.SignatureSize {
+			if sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err != nil {
+				return fmt.Errorf("[%s] verify '%s': bad signature file: %s", tag, path, err)
+			}
+		}
+
+		if !ed25519.Verify(pub, data, sig) {
+			return fmt.Errorf("[%s] verify '%s': bad signature", tag, path)
+		}
+		return nil
+	})
+}
+
+func init() {
+	reset.Register(func() {
+		verifier = nil
+	}, nil)
+}
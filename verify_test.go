@@ -0,0 +1,106 @@
+package config_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+
+	var (
+		cfg     = setupTestConfig()
+		content = []byte(`[server]
+Addr = ":8080"
+`)
+
+		writeFile = func(path string, data []byte) {
+			Ω(ioutil.WriteFile(path, data, os.ModePerm)).Should(Succeed())
+		}
+	)
+
+	BeforeEach(func() {
+		Register("server", func() Option {
+			return &AddrOption{Addr: ":80"}
+		})
+		writeFile(cfg.file, content)
+	})
+
+	Context("Checksum", func() {
+
+		BeforeEach(func() {
+			SetVerifier(ChecksumVerifier())
+		})
+
+		It("Succeed", func() {
+			sum := sha256.Sum256(content)
+			writeFile(cfg.file+".sha256", []byte(hex.EncodeToString(sum[:])+"  app.conf\n"))
+			Ω(Load(cfg.file)).Should(Succeed())
+			Ω(addrOp.Addr).Should(Equal(":8080"))
+		})
+
+		It("Mismatch", func() {
+			sum := sha256.Sum256([]byte("foo"))
+			writeFile(cfg.file+".sha256", []byte(hex.EncodeToString(sum[:])))
+			Ω(Load(cfg.file)).Should(MatchError("[config] verify '" + cfg.file + "': checksum mismatch"))
+		})
+
+		It("Checksum file not exist", func() {
+			Ω(Load(cfg.file)).Should(HaveOccurred())
+		})
+
+		It("Reload keeps current options", func() {
+			sum := sha256.Sum256(content)
+			writeFile(cfg.file+".sha256", []byte(hex.EncodeToString(sum[:])))
+			Ω(Load(cfg.file)).Should(Succeed())
+
+			writeFile(cfg.file, []byte(`[server]
+Addr = ":9090"
+`))
+			Reload()
+			Ω(addrOp.Addr).Should(Equal(":8080"))
+			Ω(GetStats().ReloadFailures).Should(Equal(uint64(1)))
+		})
+
+	})
+
+	Context("Ed25519", func() {
+
+		var (
+			pub  ed25519.PublicKey
+			priv ed25519.PrivateKey
+		)
+
+		BeforeEach(func() {
+			var err error
+			pub, priv, err = ed25519.GenerateKey(nil)
+			Ω(err).Should(Succeed())
+			SetVerifier(Ed25519Verifier(pub))
+		})
+
+		It("Raw signature", func() {
+			writeFile(cfg.file+".sig", ed25519.Sign(priv, content))
+			Ω(Load(cfg.file)).Should(Succeed())
+		})
+
+		It("Base64 signature", func() {
+			writeFile(cfg.file+".sig", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, content))+"\n"))
+			Ω(Load(cfg.file)).Should(Succeed())
+		})
+
+		It("Bad signature", func() {
+			writeFile(cfg.file+".sig", ed25519.Sign(priv, []byte("foo")))
+			Ω(Load(cfg.file)).Should(MatchError("[config] verify '" + cfg.file + "': bad signature"))
+		})
+
+	})
+
+})
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/redforks/testing/reset"
+)
+
+// Version identifies content of effective config, or of an option.
+type Version struct {
+	// Hash is hex encoded sha256 of config content in toml format, stable
+	// across processes for the same content.
+	Hash string
+
+	// Generation starts from 1 on first load, increased each time the content
+	// changed by Reload(). 0 means not loaded.
+	Generation uint64
+}
+
+var version Version
+
+// CurrentVersion returns the version of effective config of all options.
+func CurrentVersion() Version {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	return version
+}
+
+// OptionVersion returns the version of the effective option. Returns false if
+// option not registered.
+func OptionVersion(name string) (Version, bool) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+	statsLock.Lock()
+	defer statsLock.Unlock()
+
+	for _, rec := range options {
+		if rec.name == name {
+			return rec.version, true
+		}
+	}
+	return Version{}, false
+}
+
+func hashOption(name string, op Option) string {
+	buf := bytes.Buffer{}
+	v, err := encodeOption(op)
+	if err == nil {
+		err = toml.NewEncoder(&buf).Encode(v)
+	}
+	if err != nil {
+		logWarn("can not hash option", Field{"option", name}, Field{"err", err})
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// updateVersions re-calculate hashes of stored options, increase generations
+// if changed. Versions are guarded by statsLock, they are read by GetStats()
+// from other goroutines.
+func updateVersions() {
+	names := make([]string, 0, len(options))
+	hashes := make(map[string]string, len(options))
+	for _, rec := range options {
+		names = append(names, rec.name)
+		hashes[rec.name] = hashOption(rec.name, rec.op)
+	}
+
+	// sort by name, hash not affected by Register() order.
+	sort.Strings(names)
+	sum := sha256.New()
+	for _, name := range names {
+		_, _ = sum.Write([]byte(name + "=" + hashes[name] + "\n"))
+	}
+	h := hex.EncodeToString(sum.Sum(nil))
+
+	statsLock.Lock()
+	for _, rec := range options {
+		if h := hashes[rec.name]; h != rec.version.Hash {
+			rec.version = Version{h, rec.version.Generation + 1}
+		}
+	}
+	if h != version.Hash {
+		version = Version{h, version.Generation + 1}
+	}
+	v := version
+	statsLock.Unlock()
+
+	logInfo("config version", Field{"hash", v.Hash}, Field{"generation", v.Generation})
+}
+
+func init() {
+	reset.Register(func() {
+		version = Version{}
+	}, nil)
+}
@@ -0,0 +1,67 @@
+package config_test
+
+import (
+	. "github.com/redforks/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redforks/testing/reset"
+)
+
+var _ = Describe("Version", func() {
+
+	var cfg = setupTestConfig()
+
+	It("Not loaded", func() {
+		Register("foo", newFakeOption(0))
+		Ω(CurrentVersion()).Should(Equal(Version{}))
+		fooVer, exist := OptionVersion("foo")
+		Ω(exist).Should(BeTrue())
+		Ω(fooVer).Should(Equal(Version{}))
+		_, exist = OptionVersion("bar")
+		Ω(exist).Should(BeFalse())
+	})
+
+	It("Generation", func() {
+		Register("foo", newFakeOption(0))
+		Register("bar", newFakeOption(1))
+		Ω(Load(cfg.file)).Should(Succeed())
+		v := CurrentVersion()
+		Ω(v.Generation).Should(Equal(uint64(1)))
+		Ω(v.Hash).Should(HaveLen(64))
+		barVer, _ := OptionVersion("bar")
+
+		// not changed
+		Reload()
+		Ω(CurrentVersion()).Should(Equal(v))
+
+		cfg.write(`[foo]
+Name = "foobar"
+`)
+		Reload()
+		Ω(CurrentVersion().Generation).Should(Equal(uint64(2)))
+		Ω(CurrentVersion().Hash).ShouldNot(Equal(v.Hash))
+		fooVer, _ := OptionVersion("foo")
+		Ω(fooVer.Generation).Should(Equal(uint64(2)))
+		newBarVer, _ := OptionVersion("bar")
+		Ω(newBarVer).Should(Equal(barVer))
+	})
+
+	It("Stable regardless of register order", func() {
+		Register("bar", newFakeOption(0))
+		Register("foo", newFakeOption(1))
+		Ω(Load(cfg.file)).Should(Succeed())
+		h := CurrentVersion().Hash
+
+		ResetInternal()
+		reset.Disable()
+		reset.Enable()
+		ops, initHits, applyHits = nil, nil, nil
+
+		Register("foo", newFakeOption(0))
+		Register("bar", newFakeOption(1))
+		Ω(Load(cfg.file)).Should(Succeed())
+		Ω(CurrentVersion().Hash).Should(Equal(h))
+	})
+
+})